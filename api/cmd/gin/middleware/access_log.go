@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"api/internal/auth"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog logs a single record per request once it is
+// served. Server errors are logged at error level and client
+// errors at warn level
+func AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		subject := ""
+		if p := auth.PrincipalFrom(c.Request.Context()); p != nil {
+			subject = p.Subject
+		}
+
+		slog.LogAttrs(c.Request.Context(), level, "access",
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+			slog.String("user_agent", c.Request.UserAgent()),
+			slog.String("subject", subject),
+		)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"api/internal/auth"
+	"api/internal/entity"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type authenticator interface {
+	Authenticate(r *http.Request) (*auth.Principal, error)
+}
+
+// Authenticate puts the principal of the request into its
+// context. Anonymous requests go through, RequireScope rejects
+// them on protected routes, invalid credentials are rejected
+// right away
+func Authenticate(a authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := a.Authenticate(c.Request)
+		if err != nil {
+			c.Error(entity.NewUnauthorizedError("%v", err))
+			c.Abort()
+			return
+		}
+
+		if p != nil {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		}
+
+		c.Next()
+	}
+}
+
+// RequireScope rejects the requests whose principal was not
+// granted every scope
+func RequireScope(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := auth.PrincipalFrom(c.Request.Context())
+		if p == nil {
+			c.Error(entity.NewUnauthorizedError("authentication required"))
+			c.Abort()
+			return
+		}
+
+		for _, s := range scopes {
+			if !p.HasScope(s) {
+				c.Error(entity.NewForbiddenError("missing scope %s", s))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
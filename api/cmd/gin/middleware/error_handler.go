@@ -17,7 +17,54 @@ func Error() gin.HandlerFunc {
 		if len(errs) > 0 {
 			err := errs[0]
 
-			slog.Error(err.Error())
+			slog.ErrorContext(c.Request.Context(), err.Error())
+
+			var vErr *entity.ValidationError
+			if errors.As(err, &vErr) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Bad Request",
+					"message": err.Error(),
+					"fields":  vErr.Fields,
+				})
+				return
+			}
+
+			var uErr *entity.UnauthorizedError
+			if errors.As(err, &uErr) {
+				c.Header("WWW-Authenticate", `Bearer, ApiKey header="X-API-Key"`)
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error":   "Unauthorized",
+					"message": err.Error(),
+				})
+				return
+			}
+
+			var fErr *entity.ForbiddenError
+			if errors.As(err, &fErr) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":   "Forbidden",
+					"message": err.Error(),
+				})
+				return
+			}
+
+			var rErr *entity.RateLimitError
+			if errors.As(err, &rErr) {
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error":   "Too Many Requests",
+					"message": err.Error(),
+				})
+				return
+			}
+
+			var pErr *entity.PreconditionError
+			if errors.As(err, &pErr) {
+				c.JSON(http.StatusPreconditionFailed, gin.H{
+					"error":   "Precondition Failed",
+					"message": err.Error(),
+				})
+				return
+			}
 
 			var bErr *entity.BusinessError
 			if errors.As(err, &bErr) {
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"api/cmd/gin/openapi"
+	"api/internal/entity"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// ValidateRequests checks the parameters, and JSON bodies, of the
+// requests to the routes under prefix against their operation in
+// doc, rejecting query parameters the operation does not declare.
+// Other bodies, such as uploads, are left to the handlers so they
+// are not buffered
+func ValidateRequests(doc *openapi3.T, prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" || !strings.HasPrefix(route, prefix) {
+			c.Next()
+			return
+		}
+
+		path := openapi.Path(strings.TrimPrefix(route, prefix))
+		item := doc.Paths.Value(path)
+		if item == nil || item.GetOperation(c.Request.Method) == nil {
+			c.Next()
+			return
+		}
+		op := item.GetOperation(c.Request.Method)
+
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: params,
+			Route: &routers.Route{
+				Spec:      doc,
+				Path:      path,
+				PathItem:  item,
+				Method:    c.Request.Method,
+				Operation: op,
+			},
+			Options: &openapi3filter.Options{
+				ExcludeRequestBody: mediaType != "application/json",
+				MultiError:         true,
+				// credentials are checked by Authenticate
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+
+		var vErr entity.ValidationError
+		unknownParams(&vErr, c, item, op)
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			addRequestError(&vErr, "request", err)
+		}
+		if vErr.HasErrors() {
+			c.Error(&vErr)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func unknownParams(vErr *entity.ValidationError, c *gin.Context, item *openapi3.PathItem, op *openapi3.Operation) {
+	known := map[string]struct{}{}
+	for _, params := range []openapi3.Parameters{item.Parameters, op.Parameters} {
+		for _, p := range params {
+			if p.Value != nil && p.Value.In == openapi3.ParameterInQuery {
+				known[p.Value.Name] = struct{}{}
+			}
+		}
+	}
+
+	query := c.Request.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := known[name]; !ok {
+			vErr.Add(name, "unknown query parameter")
+		}
+	}
+}
+
+// addRequestError flattens the errors of openapi3filter into field
+// errors, named after the parameter or the body property
+func addRequestError(vErr *entity.ValidationError, field string, err error) {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		for _, inner := range e {
+			addRequestError(vErr, field, inner)
+		}
+	case *openapi3filter.RequestError:
+		field = "body"
+		if e.Parameter != nil {
+			field = e.Parameter.Name
+		}
+		if e.Err == nil {
+			vErr.Add(field, "%s", e.Reason)
+			return
+		}
+		addRequestError(vErr, field, e.Err)
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); field == "body" && len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+		vErr.Add(field, "%s", e.Reason)
+	case *openapi3filter.ParseError:
+		vErr.Add(field, "%s", e.Error())
+	default:
+		vErr.Add(field, "%s", err)
+	}
+}
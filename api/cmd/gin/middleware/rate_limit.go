@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"api/internal/adapter"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+type rateLimiter interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (adapter.TokenBucket, error)
+}
+
+// RateLimit is a token bucket refilled at Rate tokens per second
+// holding up to Burst tokens
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits holds the limit of every route, by "METHOD /route"
+// as registered in gin, and the default of the other routes
+type RateLimits struct {
+	routes map[string]RateLimit
+	def    *RateLimit
+}
+
+// ParseRateLimits parses a ";" separated list of limits, each one
+// either "default <rate> <burst>" or "<METHOD> <route> <rate> <burst>",
+// with rate given as <n>/s, <n>/m or <n>/h, e.g.
+//
+//	default 50/s 100; GET /api/users 10/s 20; POST /api/imports 1/m 3
+func ParseRateLimits(spec string) (RateLimits, error) {
+	limits := RateLimits{routes: map[string]RateLimit{}}
+	for _, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 3 && fields[0] == "default":
+			l, err := parseRateLimit(fields[1], fields[2])
+			if err != nil {
+				return RateLimits{}, fmt.Errorf("rate limit %q: %w", entry, err)
+			}
+			limits.def = &l
+		case len(fields) == 4:
+			l, err := parseRateLimit(fields[2], fields[3])
+			if err != nil {
+				return RateLimits{}, fmt.Errorf("rate limit %q: %w", entry, err)
+			}
+			limits.routes[strings.ToUpper(fields[0])+" "+fields[1]] = l
+		default:
+			return RateLimits{}, fmt.Errorf("rate limit %q: expected \"default <rate> <burst>\" or \"<METHOD> <route> <rate> <burst>\"", entry)
+		}
+	}
+	return limits, nil
+}
+
+// ParseRateLimit parses a single limit, "<rate> <burst>" with rate
+// given as in ParseRateLimits. Returns nil when spec is empty
+func ParseRateLimit(spec string) (*RateLimit, error) {
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 0:
+		return nil, nil
+	case 2:
+	default:
+		return nil, fmt.Errorf("rate limit %q: expected \"<rate> <burst>\"", spec)
+	}
+	l, err := parseRateLimit(fields[0], fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %q: %w", spec, err)
+	}
+	return &l, nil
+}
+
+func parseRateLimit(rate, burst string) (RateLimit, error) {
+	n, unit, ok := strings.Cut(rate, "/")
+	count, err := strconv.ParseFloat(n, 64)
+	if !ok || err != nil || count <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate %q", rate)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("invalid rate unit %q, use s, m or h", unit)
+	}
+
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return RateLimit{}, fmt.Errorf("invalid burst %q", burst)
+	}
+
+	return RateLimit{Rate: count / per.Seconds(), Burst: b}, nil
+}
+
+func (l RateLimits) limit(method, route string) (RateLimit, bool) {
+	if limit, ok := l.routes[method+" "+route]; ok {
+		return limit, true
+	}
+	if l.def != nil {
+		return *l.def, true
+	}
+	return RateLimit{}, false
+}
+
+// RateLimiter limits the requests of every client per route, with
+// the buckets kept in Redis so every replica shares them. Clients
+// are told apart by their principal, or their IP when anonymous,
+// so it must run after Authenticate. Requests are let through when
+// Redis fails
+func RateLimiter(limiter rateLimiter, limits RateLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		limit, ok := limits.limit(c.Request.Method, route)
+		if route == "" || !ok {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if p := auth.PrincipalFrom(c.Request.Context()); p != nil {
+			client = p.Method + ":" + p.Subject
+		}
+		key := rateLimitKeyPrefix + client + ":" + c.Request.Method + " " + route
+
+		takeToken(c, limiter, key, limit)
+	}
+}
+
+// IPRateLimiter limits the requests of every IP across all the
+// routes. It runs before Authenticate, so credentials cannot be
+// guessed faster than limit. A nil limit lets every request through
+func IPRateLimiter(limiter rateLimiter, limit *RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit == nil {
+			c.Next()
+			return
+		}
+		takeToken(c, limiter, rateLimitKeyPrefix+"any-route:ip:"+c.ClientIP(), *limit)
+	}
+}
+
+// takeToken takes a token from the bucket of key, aborting the
+// request when it is empty
+func takeToken(c *gin.Context, limiter rateLimiter, key string, limit RateLimit) {
+	ctx := c.Request.Context()
+	bucket, err := limiter.TakeToken(ctx, key, limit.Rate, limit.Burst)
+	if err != nil {
+		slog.ErrorContext(ctx, "rate-limit", slog.Group("RateLimiter", "take token", err))
+		c.Next()
+		return
+	}
+
+	window := int(math.Ceil(float64(limit.Burst) / limit.Rate))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, window))
+	c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(bucket.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(bucket.Reset)))
+
+	if !bucket.Allowed {
+		retryAfter := ceilSeconds(bucket.RetryAfter)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.Error(entity.NewRateLimitError(bucket.RetryAfter, "rate limit exceeded, retry in %d seconds", retryAfter))
+		c.Abort()
+		return
+	}
+
+	c.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// DocsHTML is the documentation browser, it renders the document
+// served next to it at openapi.json
+//
+//go:embed docs.html
+var DocsHTML []byte
+
+// Load parses and validates the embedded document
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+var ginParam = regexp.MustCompile(`:(\w+)`)
+
+// Path converts a gin route relative to the server url of the
+// document, e.g. /users/:id, to its OpenAPI form /users/{id}
+func Path(route string) string {
+	return ginParam.ReplaceAllString(route, "{$1}")
+}
+
+// CheckRoutes reports the routes registered under prefix that are
+// not documented and the documented operations without a route, so
+// the document cannot drift from the handlers
+func CheckRoutes(doc *openapi3.T, prefix string, routes gin.RoutesInfo) error {
+	documented := map[string]struct{}{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = struct{}{}
+		}
+	}
+
+	var problems []string
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, prefix) {
+			continue
+		}
+		key := r.Method + " " + Path(strings.TrimPrefix(r.Path, prefix))
+		if _, ok := documented[key]; !ok {
+			problems = append(problems, "undocumented route "+key)
+		}
+		delete(documented, key)
+	}
+	for key := range documented {
+		problems = append(problems, "documented operation without route "+key)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
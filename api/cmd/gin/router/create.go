@@ -0,0 +1,78 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createUsecase interface {
+	Execute(ctx context.Context, user entity.User) (*entity.User, error)
+}
+
+type createRouter struct {
+	uc createUsecase
+}
+
+func NewCreateRouter(uc createUsecase) *createRouter {
+	return &createRouter{
+		uc: uc,
+	}
+}
+
+func (r *createRouter) CreateRouter(api *gin.RouterGroup) {
+	api.POST("/users", middleware.RequireScope(auth.ScopeUsersWrite), func(c *gin.Context) {
+		var (
+			req CreateUserRequest
+			v   validator
+		)
+
+		v.KnownParams(c.Request.URL.Query())
+		v.Body(c.Request.Body, &req)
+		if req.ID <= 0 {
+			v.err.Add("id", "must be a positive integer")
+		}
+		v.Required("first_name", req.FirstName)
+		v.Name("first_name", req.FirstName)
+		v.Required("last_name", req.LastName)
+		v.Name("last_name", req.LastName)
+		v.Required("email_address", req.Email)
+		v.WritableEmail("email_address", req.Email)
+		if req.ParentUserID != nil && *req.ParentUserID <= 0 {
+			v.err.Add("parent_user_id", "must be a positive integer")
+		}
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		user, err := r.uc.Execute(c.Request.Context(), entity.User{
+			ID:           req.ID,
+			FirstName:    req.FirstName,
+			LastName:     req.LastName,
+			Email:        req.Email,
+			ParentUserID: req.ParentUserID,
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		setETag(c, user.Version)
+		c.Header("Location", fmt.Sprintf("%s/%d", c.Request.URL.Path, user.ID))
+		c.JSON(http.StatusCreated, newUserByIDResponse(user))
+	})
+}
+
+type CreateUserRequest struct {
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email_address"`
+	ParentUserID *int64 `json:"parent_user_id"`
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type deleteUsecase interface {
+	Execute(ctx context.Context, id string, version *int64) (*entity.User, error)
+}
+
+type deleteRouter struct {
+	uc deleteUsecase
+}
+
+func NewDeleteRouter(uc deleteUsecase) *deleteRouter {
+	return &deleteRouter{
+		uc: uc,
+	}
+}
+
+func (r *deleteRouter) DeleteRouter(api *gin.RouterGroup) {
+	api.DELETE("/users/:id", middleware.RequireScope(auth.ScopeUsersWrite), func(c *gin.Context) {
+		var v validator
+
+		v.KnownParams(c.Request.URL.Query())
+		id := v.ID("id", c.Param("id"))
+		version := v.IfMatch(c.GetHeader("If-Match"))
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		user, err := r.uc.Execute(c.Request.Context(), id, version)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if user == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		setETag(c, user.Version)
+		c.Status(http.StatusNoContent)
+	})
+}
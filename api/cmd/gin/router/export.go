@@ -0,0 +1,248 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"api/internal/usecase"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type exportUsecase interface {
+	Execute(ctx context.Context, input usecase.SearchInput, fn func(user entity.User) error) error
+}
+
+type exportRouter struct {
+	uc exportUsecase
+}
+
+func NewExportRouter(uc exportUsecase) *exportRouter {
+	return &exportRouter{
+		uc: uc,
+	}
+}
+
+const (
+	// rows written between flushes of the response
+	exportFlushEvery = 500
+)
+
+var exportContentTypes = map[string]string{
+	"csv":   "text/csv",
+	"jsonl": "application/x-ndjson",
+}
+
+func (r *exportRouter) ExportRouter(api *gin.RouterGroup) {
+	api.GET("/users/export", middleware.RequireScope(auth.ScopeUsersRead), func(c *gin.Context) {
+		var (
+			firstName = c.Query("first_name")
+			lastName  = c.Query("last_name")
+			email     = c.Query("email_address")
+
+			fields = c.Query("fields")
+			format = c.DefaultQuery("format", "csv")
+
+			v validator
+		)
+
+		v.KnownParams(c.Request.URL.Query(), "first_name", "last_name", "email_address", "fields", "format")
+		v.Name("first_name", firstName)
+		v.Name("last_name", lastName)
+		v.Email("email_address", email)
+		v.Fields("fields", fields)
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			v.err.Add("format", "must be csv or jsonl")
+		}
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		input := usecase.SearchInput{
+			FirstName: firstName,
+			LastName:  lastName,
+			Email:     email,
+
+			Fields: fields,
+		}
+
+		columns := strings.Split(fields, ",")
+		for i := range columns {
+			columns[i] = strings.TrimSpace(columns[i])
+		}
+
+		var w exportWriter
+		if format == "csv" {
+			w = &csvExportWriter{w: csv.NewWriter(c.Writer), columns: columns}
+		} else {
+			w = &jsonlExportWriter{enc: json.NewEncoder(c.Writer), columns: columns}
+		}
+
+		// headers are only set with the first row, so a failure
+		// before it is still reported by the error middleware
+		begin := func() error {
+			c.Header("Content-Type", contentType)
+			c.Header("Content-Disposition", "attachment; filename=users."+format)
+			return w.Begin()
+		}
+
+		rows := 0
+		err := r.uc.Execute(c.Request.Context(), input, func(user entity.User) error {
+			if rows == 0 {
+				if err := begin(); err != nil {
+					return err
+				}
+			}
+			if err := w.Write(&user); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushEvery == 0 {
+				w.Flush()
+				c.Writer.Flush()
+			}
+			return nil
+		})
+
+		// once rows were sent the status can no longer change, the
+		// connection is closed halfway so the client sees a broken
+		// transfer instead of a complete looking export
+		if err != nil && c.Writer.Written() {
+			slog.ErrorContext(c.Request.Context(), "export-router", slog.Group("ExportRouter", "rows", rows, "export", err))
+			trace.SpanFromContext(c.Request.Context()).RecordError(err)
+			abortResponse(c)
+			return
+		}
+		// the rows buffered so far are dropped with the headers of
+		// the export, the error is sent in their place
+		if err != nil {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.Error(err)
+			return
+		}
+
+		if rows == 0 {
+			if err := begin(); err != nil {
+				c.Error(err)
+				return
+			}
+		}
+		w.Flush()
+		c.Status(http.StatusOK)
+	})
+}
+
+// abortResponse closes the connection of a response already
+// started without ending it, the api is served over HTTP/1.1. A
+// connection that cannot be taken over is aborted by net/http
+func abortResponse(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+// exportWriter encodes users in one of the export formats
+type exportWriter interface {
+	Begin() error
+	Write(user *entity.User) error
+	Flush()
+}
+
+type csvExportWriter struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func (e *csvExportWriter) Begin() error {
+	return e.w.Write(e.columns)
+}
+
+func (e *csvExportWriter) Write(user *entity.User) error {
+	record := make([]string, len(e.columns))
+	for i, col := range e.columns {
+		record[i] = formatField(userField(user, col))
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvExportWriter) Flush() {
+	e.w.Flush()
+}
+
+type jsonlExportWriter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (e *jsonlExportWriter) Begin() error {
+	return nil
+}
+
+func (e *jsonlExportWriter) Write(user *entity.User) error {
+	row := make(map[string]any, len(e.columns))
+	for _, col := range e.columns {
+		row[col] = userField(user, col)
+	}
+	return e.enc.Encode(row)
+}
+
+func (e *jsonlExportWriter) Flush() {}
+
+// userField returns the value of one of the validFields
+func userField(user *entity.User, field string) any {
+	switch field {
+	case "id":
+		return user.ID
+	case "first_name":
+		return user.FirstName
+	case "last_name":
+		return user.LastName
+	case "email_address":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt
+	case "deleted_at":
+		return user.DeletedAt
+	case "merged_at":
+		return user.MergedAt
+	case "parent_user_id":
+		return user.ParentUserID
+	}
+	return nil
+}
+
+// formatField formats a value returned by userField as a CSV
+// column, nil values become empty columns
+func formatField(v any) string {
+	switch val := v.(type) {
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case string:
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case *time.Time:
+		if val != nil {
+			return val.Format(time.RFC3339)
+		}
+	case *int64:
+		if val != nil {
+			return strconv.FormatInt(*val, 10)
+		}
+	}
+	return ""
+}
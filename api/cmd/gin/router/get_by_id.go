@@ -1,6 +1,8 @@
 package router
 
 import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
 	"api/internal/entity"
 	"context"
 	"net/http"
@@ -23,12 +25,19 @@ func NewGetByIDRouter(uc getByIDUsecase) *getByIDRouter {
 }
 
 func (r *getByIDRouter) GetByIDRouter(api *gin.RouterGroup) {
-	api.GET("/users/:id", func(c *gin.Context) {
+	api.GET("/users/:id", middleware.RequireScope(auth.ScopeUsersRead), func(c *gin.Context) {
 		var (
 			ctx = c.Request.Context()
-			id  = c.Param("id")
+			v   validator
 		)
 
+		v.KnownParams(c.Request.URL.Query())
+		id := v.ID("id", c.Param("id"))
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
 		user, err := r.uc.Execute(ctx, id)
 		if err != nil {
 			c.Error(err)
@@ -40,15 +49,8 @@ func (r *getByIDRouter) GetByIDRouter(api *gin.RouterGroup) {
 			return
 		}
 
-		resp := UserByIDResponse{
-			ID:           user.ID,
-			FirstName:    user.FirstName,
-			LastName:     user.LastName,
-			Email:        user.Email,
-			ParentUserID: user.ParentUserID,
-		}
-
-		c.JSON(http.StatusOK, resp)
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, newUserByIDResponse(user))
 	})
 }
 
@@ -59,3 +61,13 @@ type UserByIDResponse struct {
 	Email        string `json:"email_address"`
 	ParentUserID *int64 `json:"parent_user_id"`
 }
+
+func newUserByIDResponse(user *entity.User) UserByIDResponse {
+	return UserByIDResponse{
+		ID:           user.ID,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Email:        user.Email,
+		ParentUserID: user.ParentUserID,
+	}
+}
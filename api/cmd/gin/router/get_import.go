@@ -0,0 +1,54 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getImportUsecase interface {
+	Execute(ctx context.Context, id string) (*entity.Import, error)
+}
+
+type getImportRouter struct {
+	uc getImportUsecase
+}
+
+func NewGetImportRouter(uc getImportUsecase) *getImportRouter {
+	return &getImportRouter{
+		uc: uc,
+	}
+}
+
+func (r *getImportRouter) GetImportRouter(api *gin.RouterGroup) {
+	api.GET("/imports/:id", middleware.RequireScope(auth.ScopeUsersRead), func(c *gin.Context) {
+		var (
+			id = c.Param("id")
+			v  validator
+		)
+
+		v.KnownParams(c.Request.URL.Query())
+		v.ImportID("id", id)
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		imp, err := r.uc.Execute(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if imp == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		c.JSON(http.StatusOK, imp)
+	})
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+type graphQLServer interface {
+	Execute(ctx context.Context, query, operationName string, variables map[string]any) *graphql.Result
+}
+
+type graphQLRouter struct {
+	server graphQLServer
+}
+
+func NewGraphQLRouter(server graphQLServer) *graphQLRouter {
+	return &graphQLRouter{
+		server: server,
+	}
+}
+
+func (r *graphQLRouter) GraphQLRouter(api *gin.RouterGroup) {
+	api.POST("/graphql", middleware.RequireScope(auth.ScopeUsersRead), func(c *gin.Context) {
+		var (
+			req GraphQLRequest
+			v   validator
+		)
+
+		v.KnownParams(c.Request.URL.Query())
+		v.Body(c.Request.Body, &req)
+		v.Required("query", req.Query)
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		// as usual for GraphQL, errors go in the result
+		c.JSON(http.StatusOK, r.server.Execute(c.Request.Context(), req.Query, req.OperationName, req.Variables))
+	})
+}
+
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/net/context"
 )
 
 type cacheAdapter interface {
@@ -12,38 +15,144 @@ type cacheAdapter interface {
 }
 
 type dbAdapter interface {
-	Ping() error
+	PingContext(ctx context.Context) error
 }
 
+type queueAdapter interface {
+	Status() error
+}
+
+type consumerStatus interface {
+	Running() bool
+}
+
+const (
+	// maximum time a single readiness check may take
+	healthCheckTimeout = 2 * time.Second
+)
+
 type healthRouter struct {
-	db    dbAdapter
-	cache cacheAdapter
+	db       dbAdapter
+	cache    cacheAdapter
+	queue    queueAdapter
+	events   queueAdapter
+	consumer consumerStatus
 }
 
-func NewHealthRouter(db dbAdapter, cache cacheAdapter) *healthRouter {
+// NewHealthRouter creates the health routes, events is the
+// publisher of the user events
+func NewHealthRouter(db dbAdapter, cache cacheAdapter, queue, events queueAdapter, consumer consumerStatus) *healthRouter {
 	return &healthRouter{
-		db:    db,
-		cache: cache,
+		db:       db,
+		cache:    cache,
+		queue:    queue,
+		events:   events,
+		consumer: consumer,
 	}
 }
 
 func (r *healthRouter) HealthRouter(api *gin.RouterGroup) {
-	api.GET("/health", func(c *gin.Context) {
-		ctx := c.Request.Context()
-
-		dbStatus := "connected"
-		if err := r.db.Ping(); err != nil {
-			dbStatus = "not connected"
-		}
-
-		cacheStatus := "connected"
-		if err := r.cache.Ping(ctx); err != nil {
-			cacheStatus = "not connected"
-		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"database": dbStatus,
-			"cache":    cacheStatus,
-		})
+	// liveness only tells the process is able to serve requests
+	api.GET("/health/live", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "up"})
 	})
+
+	api.GET("/health/ready", r.ready)
+
+	// kept for compatibility, same as /health/ready
+	api.GET("/health", r.ready)
+}
+
+// ready runs every dependency check concurrently and responds
+// with 503 if any of them is down
+func (r *healthRouter) ready(c *gin.Context) {
+	checks := map[string]func(ctx context.Context) error{
+		"database": r.db.PingContext,
+		"cache":    r.cache.Ping,
+		"queue": func(context.Context) error {
+			return r.queue.Status()
+		},
+		"events": func(context.Context) error {
+			return r.events.Status()
+		},
+		"consumer": func(context.Context) error {
+			if !r.consumer.Running() {
+				return errors.New("consumer is not running")
+			}
+			return nil
+		},
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]HealthCheck, len(checks))
+		status  = http.StatusOK
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+			result := runHealthCheck(c.Request.Context(), check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result.Status != "up" {
+				status = http.StatusServiceUnavailable
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	overall := "up"
+	if status != http.StatusOK {
+		overall = "down"
+	}
+
+	c.JSON(status, HealthResponse{
+		Status: overall,
+		Checks: results,
+	})
+}
+
+// runHealthCheck runs check bounded by healthCheckTimeout
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := HealthCheck{
+		Status:    "up",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
 }
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type importUsecase interface {
+	Execute(ctx context.Context, filename string, upload io.Reader, batchSize int) (*entity.Import, error)
+}
+
+type importRouter struct {
+	uc importUsecase
+}
+
+func NewImportRouter(uc importUsecase) *importRouter {
+	return &importRouter{
+		uc: uc,
+	}
+}
+
+func (r *importRouter) ImportRouter(api *gin.RouterGroup) {
+	api.POST("/imports", middleware.RequireScope(auth.ScopeUsersWrite), func(c *gin.Context) {
+		var v validator
+
+		v.KnownParams(c.Request.URL.Query(), "batch_size")
+		batchSize := v.Int("batch_size", c.Query("batch_size"), 1, 10000, 100)
+		filename, upload, err := uploadedCSV(c.Request)
+		if err != nil {
+			v.err.Add("body", err.Error())
+		}
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		imp, err := r.uc.Execute(c.Request.Context(), filename, upload, batchSize)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.Header("Location", fmt.Sprintf("%s/%s", c.Request.URL.Path, imp.ID))
+		c.JSON(http.StatusAccepted, imp)
+	})
+}
+
+// uploadedCSV returns the uploaded CSV without buffering it, either
+// from the "file" part of a multipart form or from a text/csv body
+func uploadedCSV(req *http.Request) (string, io.Reader, error) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil, errors.New("missing or invalid Content-Type")
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return "upload.csv", req.Body, nil
+	case "multipart/form-data":
+		mr, err := req.MultipartReader()
+		if err != nil {
+			return "", nil, err
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", nil, errors.New("missing \"file\" part")
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			if part.FormName() == "file" {
+				return part.FileName(), part, nil
+			}
+		}
+	default:
+		return "", nil, fmt.Errorf("unsupported Content-Type %s", mediaType)
+	}
+}
@@ -0,0 +1,65 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mergeUsecase interface {
+	Execute(ctx context.Context, id, targetID int64) (*entity.User, error)
+}
+
+type mergeRouter struct {
+	uc mergeUsecase
+}
+
+func NewMergeRouter(uc mergeUsecase) *mergeRouter {
+	return &mergeRouter{
+		uc: uc,
+	}
+}
+
+func (r *mergeRouter) MergeRouter(api *gin.RouterGroup) {
+	api.POST("/users/:id/merge", middleware.RequireScope(auth.ScopeUsersWrite), func(c *gin.Context) {
+		var (
+			req MergeUserRequest
+			v   validator
+		)
+
+		v.KnownParams(c.Request.URL.Query())
+		id := v.ID("id", c.Param("id"))
+		v.Body(c.Request.Body, &req)
+		if req.TargetID <= 0 {
+			v.err.Add("target_id", "must be a positive integer")
+		}
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		sourceID, _ := strconv.ParseInt(id, 10, 64)
+		user, err := r.uc.Execute(c.Request.Context(), sourceID, req.TargetID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if user == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, newUserByIDResponse(user))
+	})
+}
+
+type MergeUserRequest struct {
+	TargetID int64 `json:"target_id"`
+}
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type metricsRouter struct{}
+
+func NewMetricsRouter() *metricsRouter {
+	return &metricsRouter{}
+}
+
+// MetricsRouter exposes the Prometheus metrics, it is meant to be
+// registered at the root and not under /api
+func (r *metricsRouter) MetricsRouter(root *gin.RouterGroup) {
+	root.GET("/metrics", gin.WrapH(promhttp.Handler()))
+}
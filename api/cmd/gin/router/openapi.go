@@ -0,0 +1,31 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type openAPIRouter struct {
+	spec []byte
+	docs []byte
+}
+
+// NewOpenAPIRouter serves the JSON OpenAPI document and the docs
+// page rendering it
+func NewOpenAPIRouter(spec, docs []byte) *openAPIRouter {
+	return &openAPIRouter{
+		spec: spec,
+		docs: docs,
+	}
+}
+
+func (r *openAPIRouter) OpenAPIRouter(api *gin.RouterGroup) {
+	api.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", r.spec)
+	})
+
+	api.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", r.docs)
+	})
+}
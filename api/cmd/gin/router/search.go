@@ -1,10 +1,11 @@
 package router
 
 import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
 	"api/internal/entity"
 	"api/internal/usecase"
 	"context"
-	"fmt"
 	"net/http"
 	"strings"
 
@@ -26,17 +27,24 @@ func NewSearchRouter(uc searchUsecase) *searchRouter {
 }
 
 func (r *searchRouter) SearchRouter(api *gin.RouterGroup) {
-	api.GET("/users", func(c *gin.Context) {
+	api.GET("/users", middleware.RequireScope(auth.ScopeUsersRead), func(c *gin.Context) {
 		var (
 			firstName = c.Query("first_name")
 			lastName  = c.Query("last_name")
 			email     = c.Query("email_address")
 
 			fields = c.Query("fields")
+
+			v validator
 		)
 
-		if err := isValidFields(fields); err != nil {
-			c.JSON(http.StatusBadRequest, err)
+		v.KnownParams(c.Request.URL.Query(), "first_name", "last_name", "email_address", "fields")
+		v.Name("first_name", firstName)
+		v.Name("last_name", lastName)
+		v.Email("email_address", email)
+		v.Fields("fields", fields)
+		if err := v.Err(); err != nil {
+			c.Error(err)
 			return
 		}
 
@@ -83,16 +91,6 @@ var validFields = map[string]struct{}{
 	"parent_user_id": {},
 }
 
-func isValidFields(str string) error {
-	fields := strings.Split(str, ",")
-	for _, f := range fields {
-		if _, ok := validFields[f]; !ok {
-			return fmt.Errorf("invalid field option %s", f)
-		}
-	}
-	return nil
-}
-
 func setFields(user *entity.User, fields ...string) SearchResponse {
 	s := SearchResponse{}
 	for _, field := range fields {
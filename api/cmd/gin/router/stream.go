@@ -0,0 +1,238 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/broadcast"
+	"api/internal/entity"
+	"api/internal/usecase"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamHeartbeat keeps idle streams from being closed by proxies
+	streamHeartbeat = 15 * time.Second
+	// streamWriteTimeout drops the clients not reading their stream
+	streamWriteTimeout = 10 * time.Second
+	// streamRetry is how long SSE clients wait before reconnecting
+	streamRetry = 3 * time.Second
+
+	maxStreamIDs = 100
+)
+
+type watchUsecase interface {
+	Execute(ctx context.Context, filter usecase.WatchFilter, fn func(change entity.UserChange) error) error
+}
+
+type streamRouter struct {
+	uc       watchUsecase
+	upgrader websocket.Upgrader
+}
+
+func NewStreamRouter(uc watchUsecase) *streamRouter {
+	return &streamRouter{
+		uc: uc,
+	}
+}
+
+func (r *streamRouter) StreamRouter(api *gin.RouterGroup) {
+	api.GET("/users/stream", middleware.RequireScope(auth.ScopeUsersRead), r.serverSentEvents)
+	api.GET("/users/stream/ws", middleware.RequireScope(auth.ScopeUsersRead), r.webSocket)
+}
+
+func (r *streamRouter) serverSentEvents(c *gin.Context) {
+	filter, err := streamFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	rc := http.NewResponseController(c.Writer)
+	write := func(format string, a ...any) error {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(c.Writer, format, a...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if err := write("retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+		return
+	}
+
+	changes, errs := r.watch(ctx, filter)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case change := <-changes:
+			var data []byte
+			data, err = json.Marshal(newUserChangeEvent(change))
+			if err != nil {
+				break
+			}
+			// the lost marker has no id, so the client keeps resuming
+			// from the last change it got
+			if change.ID != "" {
+				err = write("id: %s\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+			} else {
+				err = write("event: %s\ndata: %s\n\n", change.Type, data)
+			}
+		case <-heartbeat.C:
+			err = write(": ping\n\n")
+		case err := <-errs:
+			if err != nil {
+				slog.ErrorContext(ctx, "stream-router", slog.Group("serverSentEvents", "watch", err))
+				data, _ := json.Marshal(gin.H{"message": err.Error()})
+				write("event: error\ndata: %s\n\n", data)
+			}
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "stream-router", slog.Group("serverSentEvents", "write", err))
+			return
+		}
+	}
+}
+
+func (r *streamRouter) webSocket(c *gin.Context) {
+	filter, err := streamFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// the upgrader replies to the failed handshakes
+	conn, err := r.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	// the client sends nothing but control frames, reading them
+	// answers its pings and notices when it goes away
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	changes, errs := r.watch(ctx, filter)
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case change := <-changes:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			err = conn.WriteJSON(newUserChangeEvent(change))
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+		case err := <-errs:
+			code, reason := websocket.CloseNormalClosure, ""
+			if errors.Is(err, broadcast.ErrSlowSubscriber) {
+				code, reason = websocket.CloseTryAgainLater, err.Error()
+			} else if err != nil {
+				slog.ErrorContext(ctx, "stream-router", slog.Group("webSocket", "watch", err))
+				code, reason = websocket.CloseInternalServerErr, "internal error"
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(streamWriteTimeout))
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// watch runs the watch usecase in background. The changes are handed
+// over one at a time, so a client reading slowly makes its
+// subscription fall behind, and eventually be dropped, instead of
+// holding back the others
+func (r *streamRouter) watch(ctx context.Context, filter usecase.WatchFilter) (<-chan entity.UserChange, <-chan error) {
+	changes := make(chan entity.UserChange)
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- r.uc.Execute(ctx, filter, func(change entity.UserChange) error {
+			select {
+			case changes <- change:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	return changes, errs
+}
+
+// streamFilter reads the filter of both streams, the Last-Event-ID
+// header sent by the SSE clients when reconnecting wins over the
+// last_event_id param
+func streamFilter(c *gin.Context) (usecase.WatchFilter, error) {
+	var v validator
+
+	v.KnownParams(c.Request.URL.Query(), "ids", "name_prefix", "last_event_id")
+	filter := usecase.WatchFilter{
+		IDs:         v.IDs("ids", c.Query("ids"), maxStreamIDs),
+		NamePrefix:  c.Query("name_prefix"),
+		LastEventID: c.GetHeader("Last-Event-ID"),
+	}
+	v.Name("name_prefix", filter.NamePrefix)
+	if filter.LastEventID == "" {
+		filter.LastEventID = c.Query("last_event_id")
+	}
+
+	return filter, v.Err()
+}
+
+type UserChangeEvent struct {
+	ID   string            `json:"id,omitempty"`
+	Type string            `json:"type"`
+	At   time.Time         `json:"at"`
+	User *UserByIDResponse `json:"user,omitempty"`
+}
+
+func newUserChangeEvent(change entity.UserChange) UserChangeEvent {
+	event := UserChangeEvent{
+		ID:   change.ID,
+		Type: string(change.Type),
+		At:   change.At,
+	}
+	if change.Type != entity.ChangesLost {
+		user := newUserByIDResponse(&change.User)
+		event.User = &user
+	}
+	return event
+}
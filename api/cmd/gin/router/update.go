@@ -0,0 +1,85 @@
+package router
+
+import (
+	"api/cmd/gin/middleware"
+	"api/internal/auth"
+	"api/internal/entity"
+	"api/internal/usecase"
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateUsecase interface {
+	Execute(ctx context.Context, id string, input usecase.UpdateInput, version *int64) (*entity.User, error)
+}
+
+type updateRouter struct {
+	uc updateUsecase
+}
+
+func NewUpdateRouter(uc updateUsecase) *updateRouter {
+	return &updateRouter{
+		uc: uc,
+	}
+}
+
+func (r *updateRouter) UpdateRouter(api *gin.RouterGroup) {
+	api.PATCH("/users/:id", middleware.RequireScope(auth.ScopeUsersWrite), func(c *gin.Context) {
+		var (
+			req UpdateUserRequest
+			v   validator
+		)
+
+		v.KnownParams(c.Request.URL.Query())
+		id := v.ID("id", c.Param("id"))
+		version := v.IfMatch(c.GetHeader("If-Match"))
+		v.Body(c.Request.Body, &req)
+		if req.FirstName == nil && req.LastName == nil && req.Email == nil {
+			v.err.Add("body", "at least one field must be given")
+		}
+		if req.FirstName != nil {
+			v.Required("first_name", *req.FirstName)
+			v.Name("first_name", *req.FirstName)
+		}
+		if req.LastName != nil {
+			v.Required("last_name", *req.LastName)
+			v.Name("last_name", *req.LastName)
+		}
+		if req.Email != nil {
+			v.Required("email_address", *req.Email)
+			v.WritableEmail("email_address", *req.Email)
+		}
+		if err := v.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+
+		input := usecase.UpdateInput{
+			FirstName: req.FirstName,
+			LastName:  req.LastName,
+			Email:     req.Email,
+		}
+
+		user, err := r.uc.Execute(c.Request.Context(), id, input, version)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		if user == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		setETag(c, user.Version)
+		c.JSON(http.StatusOK, newUserByIDResponse(user))
+	})
+}
+
+type UpdateUserRequest struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Email     *string `json:"email_address"`
+}
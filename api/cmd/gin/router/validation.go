@@ -0,0 +1,203 @@
+package router
+
+import (
+	"api/internal/entity"
+	"crypto/aes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// limits follow the column sizes of challenge.users
+	maxNameLength  = 100
+	maxEmailLength = 255
+
+	// emails are stored AES encrypted and hex encoded, which
+	// must still fit the email_address column
+	maxWritableEmailLength = (maxEmailLength - 2*aes.BlockSize) / 2
+)
+
+// validator collects field level errors while validating
+// the path, query, header and body parameters of a request
+type validator struct {
+	err entity.ValidationError
+}
+
+// ID validates that value is a positive numeric id and
+// returns it in its canonical form
+func (v *validator) ID(field, value string) string {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		v.err.Add(field, "must be a positive integer")
+		return value
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+// IDs validates an optional comma separated list of at most
+// max positive numeric ids
+func (v *validator) IDs(field, value string, max int) []int64 {
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) > max {
+		v.err.Add(field, "must have at most %d ids", max)
+		return nil
+	}
+
+	ids := make([]int64, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil || id <= 0 {
+			v.err.Add(field, "must be a list of positive integers")
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Name validates an optional name, allowing letters,
+// spaces, hyphens, apostrophes and dots only
+func (v *validator) Name(field, value string) {
+	if value == "" {
+		return
+	}
+	if utf8.RuneCountInString(value) > maxNameLength {
+		v.err.Add(field, "must have at most %d characters", maxNameLength)
+		return
+	}
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !strings.ContainsRune(" -'.", r) {
+			v.err.Add(field, "contains invalid character %q", r)
+			return
+		}
+	}
+}
+
+// Email validates an optional email address
+func (v *validator) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	if len(value) > maxEmailLength {
+		v.err.Add(field, "must have at most %d characters", maxEmailLength)
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		v.err.Add(field, "must be a valid email address")
+	}
+}
+
+// WritableEmail validates an email address that is going to be stored
+func (v *validator) WritableEmail(field, value string) {
+	if len(value) > maxWritableEmailLength {
+		v.err.Add(field, "must have at most %d characters", maxWritableEmailLength)
+		return
+	}
+	v.Email(field, value)
+}
+
+// Required rejects empty values
+func (v *validator) Required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.err.Add(field, "is required")
+	}
+}
+
+// Body decodes the JSON request body into dst, rejecting
+// unknown fields
+func (v *validator) Body(body io.Reader, dst any) {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		v.err.Add("body", "invalid JSON: %v", err)
+	}
+}
+
+// IfMatch parses an optional If-Match header holding a version
+// ETag. Returns nil when the header is absent or "*"
+func (v *validator) IfMatch(value string) *int64 {
+	if value == "" || value == "*" {
+		return nil
+	}
+	version, ok := parseETag(value)
+	if !ok {
+		v.err.Add("If-Match", "must be an ETag returned by the api")
+		return nil
+	}
+	return &version
+}
+
+// Fields validates a comma separated list of user fields
+func (v *validator) Fields(field, value string) {
+	if value == "" {
+		v.err.Add(field, "is required")
+		return
+	}
+	for _, f := range strings.Split(value, ",") {
+		if _, ok := validFields[strings.TrimSpace(f)]; !ok {
+			v.err.Add(field, "invalid field option %s", f)
+		}
+	}
+}
+
+// Int validates an optional integer within [min, max],
+// returning def when value is empty
+func (v *validator) Int(field, value string, min, max, def int) int {
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		v.err.Add(field, "must be an integer between %d and %d", min, max)
+		return def
+	}
+	return n
+}
+
+// ImportID validates the id of an import
+func (v *validator) ImportID(field, value string) {
+	if b, err := hex.DecodeString(value); err != nil || len(b) != 16 {
+		v.err.Add(field, "must be an import id")
+	}
+}
+
+// KnownParams rejects any query parameter not listed in allowed
+func (v *validator) KnownParams(query url.Values, allowed ...string) {
+	known := make(map[string]struct{}, len(allowed))
+	for _, a := range allowed {
+		known[a] = struct{}{}
+	}
+
+	params := make([]string, 0, len(query))
+	for p := range query {
+		params = append(params, p)
+	}
+	sort.Strings(params)
+
+	for _, p := range params {
+		if _, ok := known[p]; !ok {
+			v.err.Add(p, "unknown query parameter")
+		}
+	}
+}
+
+// Err returns the collected errors, or nil when the input is valid
+func (v *validator) Err() error {
+	if !v.err.HasErrors() {
+		return nil
+	}
+	return &v.err
+}
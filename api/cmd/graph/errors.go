@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"api/internal/entity"
+	"errors"
+	"log/slog"
+)
+
+// codedError carries the code of an error in the extensions of the
+// GraphQL error, the same classes the HTTP error middleware maps
+type codedError struct {
+	err        error
+	extensions map[string]any
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+func (e *codedError) Extensions() map[string]any {
+	return e.extensions
+}
+
+func toGraphQLError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		vErr *entity.ValidationError
+		uErr *entity.UnauthorizedError
+		fErr *entity.ForbiddenError
+		pErr *entity.PreconditionError
+		bErr *entity.BusinessError
+	)
+	switch {
+	case errors.As(err, &vErr):
+		return &codedError{err, map[string]any{"code": "BAD_USER_INPUT", "fields": vErr.Fields}}
+	case errors.As(err, &uErr):
+		return &codedError{err, map[string]any{"code": "UNAUTHENTICATED"}}
+	case errors.As(err, &fErr):
+		return &codedError{err, map[string]any{"code": "FORBIDDEN"}}
+	case errors.As(err, &pErr):
+		return &codedError{err, map[string]any{"code": "PRECONDITION_FAILED"}}
+	case errors.As(err, &bErr):
+		return &codedError{err, map[string]any{"code": "CONFLICT"}}
+	}
+
+	slog.Error(err.Error())
+	return &codedError{err, map[string]any{"code": "INTERNAL_SERVER_ERROR"}}
+}
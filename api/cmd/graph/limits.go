@@ -0,0 +1,159 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listCost is the number of items a list field is assumed to
+// return, multiplying the cost of its selection
+const listCost = 10
+
+// Limits bound the queries accepted by the server
+type Limits struct {
+	// MaxDepth is the deepest field nesting allowed
+	MaxDepth int
+	// MaxComplexity is the highest cost allowed, every field
+	// costs 1 and the selections of lists cost listCost times
+	MaxComplexity int
+}
+
+type limitError struct {
+	msg string
+}
+
+func (e *limitError) Error() string {
+	return e.msg
+}
+
+func (e *limitError) Extensions() map[string]any {
+	return map[string]any{"code": "QUERY_TOO_COMPLEX"}
+}
+
+// queryCost walks the operation of a validated document, failing
+// as soon as it goes over one of the limits. Introspection fields
+// are not counted
+type queryCost struct {
+	limits    Limits
+	fragments map[string]*ast.FragmentDefinition
+	schema    *graphql.Schema
+}
+
+func checkLimits(schema *graphql.Schema, doc *ast.Document, operationName string, limits Limits) *limitError {
+	q := queryCost{
+		limits:    limits,
+		fragments: map[string]*ast.FragmentDefinition{},
+		schema:    schema,
+	}
+
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			q.fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		// the executor reports the missing operation
+		return nil
+	}
+
+	if _, err := q.selection(op.SelectionSet, schema.QueryType(), 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+// selection returns the cost of set, selected on parent at depth
+func (q *queryCost) selection(set *ast.SelectionSet, parent graphql.Type, depth int) (int, *limitError) {
+	if set == nil {
+		return 0, nil
+	}
+
+	cost := 0
+	for _, sel := range set.Selections {
+		var (
+			c   int
+			err *limitError
+		)
+		switch sel := sel.(type) {
+		case *ast.Field:
+			c, err = q.field(sel, parent, depth+1)
+		case *ast.InlineFragment:
+			c, err = q.selection(sel.SelectionSet, q.condition(sel.TypeCondition, parent), depth)
+		case *ast.FragmentSpread:
+			if frag, ok := q.fragments[sel.Name.Value]; ok {
+				c, err = q.selection(frag.SelectionSet, q.condition(frag.TypeCondition, parent), depth)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		cost += c
+		if cost > q.limits.MaxComplexity {
+			return 0, &limitError{fmt.Sprintf("query complexity is over the limit of %d", q.limits.MaxComplexity)}
+		}
+	}
+	return cost, nil
+}
+
+func (q *queryCost) field(field *ast.Field, parent graphql.Type, depth int) (int, *limitError) {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return 0, nil
+	}
+	if depth > q.limits.MaxDepth {
+		return 0, &limitError{fmt.Sprintf("query depth is over the limit of %d", q.limits.MaxDepth)}
+	}
+
+	fieldType, multiplier := q.fieldType(parent, field.Name.Value)
+	cost, err := q.selection(field.SelectionSet, fieldType, depth)
+	if err != nil {
+		return 0, err
+	}
+	return 1 + multiplier*cost, nil
+}
+
+// fieldsType is implemented by the object and interface types
+type fieldsType interface {
+	Fields() graphql.FieldDefinitionMap
+}
+
+// fieldType returns the named type of a field and the multiplier
+// of its selection
+func (q *queryCost) fieldType(parent graphql.Type, name string) (graphql.Type, int) {
+	fields, ok := parent.(fieldsType)
+	if !ok {
+		return nil, 1
+	}
+	def, ok := fields.Fields()[name]
+	if !ok {
+		return nil, 1
+	}
+
+	t, multiplier := def.Type, 1
+	if nn, ok := t.(*graphql.NonNull); ok {
+		t = nn.OfType
+	}
+	if list, ok := t.(*graphql.List); ok {
+		t, multiplier = list.OfType, listCost
+	}
+	if nn, ok := t.(*graphql.NonNull); ok {
+		t = nn.OfType
+	}
+	return t, multiplier
+}
+
+func (q *queryCost) condition(cond *ast.Named, parent graphql.Type) graphql.Type {
+	if cond == nil || cond.Name == nil {
+		return parent
+	}
+	return q.schema.Type(cond.Name.Value)
+}
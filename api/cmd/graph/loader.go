@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// batchLoader collects the keys requested while resolving a level
+// of the query and fetches them with a single call the first time
+// one of them is needed. The executor resolves a whole level before
+// calling the thunks, so a level costs one fetch
+type batchLoader[T any] struct {
+	fetch func(ctx context.Context, keys []int64) (map[int64]T, error)
+
+	mu      sync.Mutex
+	pending []int64
+	results map[int64]T
+	errs    map[int64]error
+}
+
+func newBatchLoader[T any](fetch func(ctx context.Context, keys []int64) (map[int64]T, error)) *batchLoader[T] {
+	return &batchLoader[T]{
+		fetch:   fetch,
+		results: map[int64]T{},
+		errs:    map[int64]error{},
+	}
+}
+
+// Load queues key and returns a thunk returning its value, or
+// false when the key was not found
+func (l *batchLoader[T]) Load(ctx context.Context, key int64) func() (T, bool, error) {
+	l.mu.Lock()
+	_, done := l.results[key]
+	if _, failed := l.errs[key]; !done && !failed {
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (T, bool, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			l.dispatch(ctx)
+		}
+		if err, ok := l.errs[key]; ok {
+			var zero T
+			return zero, false, err
+		}
+		v, ok := l.results[key]
+		return v, ok, nil
+	}
+}
+
+// dispatch fetches the pending keys, must be called with the lock held
+func (l *batchLoader[T]) dispatch(ctx context.Context) {
+	keys := make([]int64, 0, len(l.pending))
+	seen := make(map[int64]struct{}, len(l.pending))
+	for _, k := range l.pending {
+		if _, ok := seen[k]; !ok {
+			seen[k] = struct{}{}
+			keys = append(keys, k)
+		}
+	}
+	l.pending = l.pending[:0]
+
+	values, err := l.fetch(ctx, keys)
+	for _, k := range keys {
+		if err != nil {
+			l.errs[k] = err
+			continue
+		}
+		if v, ok := values[k]; ok {
+			l.results[k] = v
+		}
+	}
+	// keys not found are remembered as such
+	if err == nil {
+		for _, k := range keys {
+			if _, ok := l.results[k]; !ok {
+				var zero T
+				l.results[k] = zero
+			}
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package graph
+
+import (
+	"api/internal/entity"
+	"api/internal/usecase"
+	"context"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const maxNameLength = 100
+
+// searchFields are the fields read by the users query and by the
+// parent and children lookups, every field of the User type is read
+// from them. The email is only requested when selected, see emailFields
+const (
+	searchFields = "id,first_name,last_name,parent_user_id,created_at,deleted_at,merged_at"
+	emailFields  = searchFields + ",email_address"
+)
+
+type getByIDUsecase interface {
+	Execute(ctx context.Context, id string) (*entity.User, error)
+}
+
+type searchUsecase interface {
+	Execute(ctx context.Context, filter usecase.SearchInput) ([]entity.User, error)
+}
+
+type relativesUsecase interface {
+	Users(ctx context.Context, ids []int64, fields string) (map[int64]entity.User, error)
+	Children(ctx context.Context, parentIDs []int64, fields string) (map[int64][]entity.User, error)
+}
+
+type resolver struct {
+	getByID   getByIDUsecase
+	search    searchUsecase
+	relatives relativesUsecase
+}
+
+func (r *resolver) schema() (graphql.Schema, error) {
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: userField(func(u entity.User) any {
+					return strconv.FormatInt(u.ID, 10)
+				}),
+			},
+			"firstName": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: userField(func(u entity.User) any { return u.FirstName }),
+			},
+			"lastName": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.String),
+				Resolve: userField(func(u entity.User) any { return u.LastName }),
+			},
+			"emailAddress": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Masked unless the caller has the users:read_pii scope",
+				Resolve:     userField(func(u entity.User) any { return u.Email }),
+			},
+			"parentUserId": &graphql.Field{
+				Type: graphql.ID,
+				Resolve: userField(func(u entity.User) any {
+					if u.ParentUserID == nil {
+						return nil
+					}
+					return strconv.FormatInt(*u.ParentUserID, 10)
+				}),
+			},
+			"createdAt": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.DateTime),
+				Resolve: userField(func(u entity.User) any { return u.CreatedAt }),
+			},
+			"deletedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: userField(func(u entity.User) any {
+					if u.DeletedAt == nil {
+						return nil
+					}
+					return *u.DeletedAt
+				}),
+			},
+			"mergedAt": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: userField(func(u entity.User) any {
+					if u.MergedAt == nil {
+						return nil
+					}
+					return *u.MergedAt
+				}),
+			},
+			"version": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.Int),
+				Resolve: userField(func(u entity.User) any { return u.Version }),
+			},
+		},
+	})
+
+	// parent and children refer to the User type itself
+	user.AddFieldConfig("parent", &graphql.Field{
+		Type:    user,
+		Resolve: r.parent,
+	})
+	user.AddFieldConfig("children", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user))),
+		Resolve: r.children,
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: user,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.user,
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(user))),
+				Args: graphql.FieldConfigArgument{
+					"firstName":    &graphql.ArgumentConfig{Type: graphql.String},
+					"lastName":     &graphql.ArgumentConfig{Type: graphql.String},
+					"emailAddress": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: r.users,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (any, error) {
+	raw, _ := p.Args["id"].(string)
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		var vErr entity.ValidationError
+		vErr.Add("id", "must be a positive integer")
+		return nil, toGraphQLError(&vErr)
+	}
+
+	user, err := r.getByID.Execute(p.Context, strconv.FormatInt(id, 10))
+	if err != nil || user == nil {
+		return nil, toGraphQLError(err)
+	}
+	return *user, nil
+}
+
+func (r *resolver) users(p graphql.ResolveParams) (any, error) {
+	input := usecase.SearchInput{Fields: searchFields}
+	if selects(p.Info.FieldASTs, p.Info.Fragments, "emailAddress") {
+		input.Fields = emailFields
+	}
+	input.FirstName, _ = p.Args["firstName"].(string)
+	input.LastName, _ = p.Args["lastName"].(string)
+	input.Email, _ = p.Args["emailAddress"].(string)
+
+	var vErr entity.ValidationError
+	if utf8.RuneCountInString(input.FirstName) > maxNameLength {
+		vErr.Add("firstName", "must have at most %d characters", maxNameLength)
+	}
+	if utf8.RuneCountInString(input.LastName) > maxNameLength {
+		vErr.Add("lastName", "must have at most %d characters", maxNameLength)
+	}
+	if vErr.HasErrors() {
+		return nil, toGraphQLError(&vErr)
+	}
+
+	users, err := r.search.Execute(p.Context, input)
+	if err != nil {
+		return nil, toGraphQLError(err)
+	}
+	if users == nil {
+		users = []entity.User{}
+	}
+	return users, nil
+}
+
+func (r *resolver) parent(p graphql.ResolveParams) (any, error) {
+	user, ok := p.Source.(entity.User)
+	if !ok || user.ParentUserID == nil {
+		return nil, nil
+	}
+
+	loader := loadersFrom(p.Context).users
+	if selects(p.Info.FieldASTs, p.Info.Fragments, "emailAddress") {
+		loader = loadersFrom(p.Context).usersWithEmail
+	}
+
+	load := loader.Load(p.Context, *user.ParentUserID)
+	return func() (any, error) {
+		parent, found, err := load()
+		if err != nil || !found {
+			return nil, toGraphQLError(err)
+		}
+		return parent, nil
+	}, nil
+}
+
+func (r *resolver) children(p graphql.ResolveParams) (any, error) {
+	user, ok := p.Source.(entity.User)
+	if !ok {
+		return nil, fmt.Errorf("unexpected source %T", p.Source)
+	}
+
+	loader := loadersFrom(p.Context).children
+	if selects(p.Info.FieldASTs, p.Info.Fragments, "emailAddress") {
+		loader = loadersFrom(p.Context).childrenWithEmail
+	}
+
+	load := loader.Load(p.Context, user.ID)
+	return func() (any, error) {
+		children, _, err := load()
+		if err != nil {
+			return nil, toGraphQLError(err)
+		}
+		if children == nil {
+			children = []entity.User{}
+		}
+		return children, nil
+	}, nil
+}
+
+// selects reports whether name is selected on the resolved fields,
+// directly or through their fragments
+func selects(fields []*ast.Field, fragments map[string]ast.Definition, name string) bool {
+	var inSet func(set *ast.SelectionSet) bool
+	inSet = func(set *ast.SelectionSet) bool {
+		if set == nil {
+			return false
+		}
+		for _, sel := range set.Selections {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if sel.Name.Value == name {
+					return true
+				}
+			case *ast.InlineFragment:
+				if inSet(sel.SelectionSet) {
+					return true
+				}
+			case *ast.FragmentSpread:
+				if frag, ok := fragments[sel.Name.Value].(*ast.FragmentDefinition); ok && inSet(frag.SelectionSet) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, f := range fields {
+		if inSet(f.SelectionSet) {
+			return true
+		}
+	}
+	return false
+}
+
+// userField resolves a field of the entity.User being resolved
+func userField(fn func(u entity.User) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		user, ok := p.Source.(entity.User)
+		if !ok {
+			return nil, fmt.Errorf("unexpected source %T", p.Source)
+		}
+		return fn(user), nil
+	}
+}
+
+type loadersKey struct{}
+
+// loaders batch the parent and children lookups of a single request,
+// the ones selecting the email apart from the others
+type loaders struct {
+	users             *batchLoader[entity.User]
+	usersWithEmail    *batchLoader[entity.User]
+	children          *batchLoader[[]entity.User]
+	childrenWithEmail *batchLoader[[]entity.User]
+}
+
+func withLoaders(ctx context.Context, relatives relativesUsecase) context.Context {
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		users:             newBatchLoader(withFields(relatives.Users, searchFields)),
+		usersWithEmail:    newBatchLoader(withFields(relatives.Users, emailFields)),
+		children:          newBatchLoader(withFields(relatives.Children, searchFields)),
+		childrenWithEmail: newBatchLoader(withFields(relatives.Children, emailFields)),
+	})
+}
+
+// withFields binds the fields read by a relatives lookup
+func withFields[T any](fetch func(ctx context.Context, keys []int64, fields string) (map[int64]T, error), fields string) func(ctx context.Context, keys []int64) (map[int64]T, error) {
+	return func(ctx context.Context, keys []int64) (map[int64]T, error) {
+		return fetch(ctx, keys, fields)
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type server struct {
+	schema    graphql.Schema
+	relatives relativesUsecase
+	limits    Limits
+}
+
+// NewServer builds the GraphQL schema over the same usecases as the
+// HTTP routers, parent and children are loaded in batches
+func NewServer(getByID getByIDUsecase, search searchUsecase, relatives relativesUsecase, limits Limits) (*server, error) {
+	r := &resolver{
+		getByID:   getByID,
+		search:    search,
+		relatives: relatives,
+	}
+
+	schema, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	return &server{
+		schema:    schema,
+		relatives: relatives,
+		limits:    limits,
+	}, nil
+}
+
+// Execute runs a query, the errors are reported in the result
+func (s *server) Execute(ctx context.Context, query, operationName string, variables map[string]any) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if res := graphql.ValidateDocument(&s.schema, doc, nil); !res.IsValid {
+		return &graphql.Result{Errors: res.Errors}
+	}
+
+	if err := checkLimits(&s.schema, doc, operationName, s.limits); err != nil {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{{
+			Message:    err.Error(),
+			Locations:  []location.SourceLocation{},
+			Extensions: err.Extensions(),
+		}}}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: operationName,
+		Args:          variables,
+		Context:       withLoaders(ctx, s.relatives),
+	})
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"api/internal/broadcast"
+	"api/internal/entity"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps the errors of the usecases the same way the HTTP
+// error middleware does
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var (
+		vErr *entity.ValidationError
+		uErr *entity.UnauthorizedError
+		fErr *entity.ForbiddenError
+		pErr *entity.PreconditionError
+		bErr *entity.BusinessError
+		rErr *entity.RateLimitError
+	)
+	switch {
+	case errors.As(err, &vErr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &uErr):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.As(err, &fErr):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &pErr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &bErr):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &rErr):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, broadcast.ErrSlowSubscriber):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+
+	slog.Error(err.Error())
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"api/internal/adapter"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type authenticator interface {
+	AuthenticateCredentials(key, header string) (*auth.Principal, error)
+}
+
+type rateLimiter interface {
+	TakeToken(ctx context.Context, key string, rate float64, burst int) (adapter.TokenBucket, error)
+}
+
+// MethodScopes are the scopes required by every method, methods
+// missing from it are rejected
+type MethodScopes map[string][]string
+
+// AuthUnaryInterceptor authenticates the calls with the same
+// credentials as the HTTP api, sent as metadata
+func AuthUnaryInterceptor(a authenticator, scopes MethodScopes) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authorize(ctx, a, scopes, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor is AuthUnaryInterceptor for streams
+func AuthStreamInterceptor(a authenticator, scopes MethodScopes) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorize(ss.Context(), a, scopes, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// IPRateLimitUnaryInterceptor limits the calls of every peer IP to
+// rate per second with up to burst at once, sharing the bucket of the
+// HTTP api. It runs before AuthUnaryInterceptor, so credentials cannot
+// be guessed faster. Calls are let through when Redis fails
+func IPRateLimitUnaryInterceptor(limiter rateLimiter, rate float64, burst int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := takeToken(ctx, limiter, rate, burst); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// IPRateLimitStreamInterceptor is IPRateLimitUnaryInterceptor for
+// streams, only their opening takes a token
+func IPRateLimitStreamInterceptor(limiter rateLimiter, rate float64, burst int) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := takeToken(ss.Context(), limiter, rate, burst); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func takeToken(ctx context.Context, limiter rateLimiter, rate float64, burst int) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		ip = p.Addr.String()
+	}
+
+	bucket, err := limiter.TakeToken(ctx, "ratelimit:any-route:ip:"+ip, rate, burst)
+	if err != nil {
+		slog.ErrorContext(ctx, "grpc-service", slog.Group("takeToken", "take token", err))
+		return nil
+	}
+	if !bucket.Allowed {
+		return toStatus(entity.NewRateLimitError(bucket.RetryAfter, "rate limit exceeded, retry in %d seconds", int(math.Ceil(bucket.RetryAfter.Seconds()))))
+	}
+	return nil
+}
+
+// RecoveryUnaryInterceptor turns a panic of a call into an
+// Internal error instead of taking the server down
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer recoverCall(ctx, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor is RecoveryUnaryInterceptor for streams
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverCall(ss.Context(), info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+func recoverCall(ctx context.Context, method string, err *error) {
+	if r := recover(); r != nil {
+		slog.ErrorContext(ctx, "grpc-service", slog.Group("recover", "method", method, "panic", r, "stack", string(debug.Stack())))
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+func authorize(ctx context.Context, a authenticator, scopes MethodScopes, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p, err := a.AuthenticateCredentials(first(md, "x-api-key"), first(md, "authorization"))
+	if err != nil {
+		return nil, toStatus(entity.NewUnauthorizedError("%v", err))
+	}
+	if p == nil {
+		return nil, toStatus(entity.NewUnauthorizedError("authentication required"))
+	}
+
+	required, ok := scopes[method]
+	if !ok {
+		return nil, toStatus(entity.NewForbiddenError("method %s is not allowed", method))
+	}
+	for _, s := range required {
+		if !p.HasScope(s) {
+			return nil, toStatus(entity.NewForbiddenError("missing scope %s", s))
+		}
+	}
+
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream overrides the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
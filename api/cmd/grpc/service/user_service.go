@@ -0,0 +1,167 @@
+package service
+
+import (
+	"api/cmd/grpc/userpb"
+	"api/internal/entity"
+	"api/internal/usecase"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxNameLength = 100
+
+// allFields are returned by SearchUsers when no field is requested
+var allFields = []string{"id", "first_name", "last_name", "email_address", "parent_user_id", "created_at", "deleted_at", "merged_at"}
+
+type getByIDUsecase interface {
+	Execute(ctx context.Context, id string) (*entity.User, error)
+}
+
+type searchUsecase interface {
+	Execute(ctx context.Context, filter usecase.SearchInput) ([]entity.User, error)
+}
+
+type watchUsecase interface {
+	Execute(ctx context.Context, filter usecase.WatchFilter, fn func(change entity.UserChange) error) error
+}
+
+type userService struct {
+	userpb.UnimplementedUserServiceServer
+
+	getByID getByIDUsecase
+	search  searchUsecase
+	watch   watchUsecase
+}
+
+// NewUserService implements the gRPC UserService over the same
+// usecases as the HTTP routers
+func NewUserService(getByID getByIDUsecase, search searchUsecase, watch watchUsecase) *userService {
+	return &userService{
+		getByID: getByID,
+		search:  search,
+		watch:   watch,
+	}
+}
+
+func (s *userService) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	if req.GetId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id: must be a positive integer")
+	}
+
+	user, err := s.getByID.Execute(ctx, strconv.FormatInt(req.GetId(), 10))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if user == nil {
+		return nil, status.Errorf(codes.NotFound, "user %d not found", req.GetId())
+	}
+
+	return newUser(user, allFields), nil
+}
+
+func (s *userService) SearchUsers(req *userpb.SearchUsersRequest, stream userpb.UserService_SearchUsersServer) error {
+	var vErr entity.ValidationError
+	if utf8.RuneCountInString(req.GetFirstName()) > maxNameLength {
+		vErr.Add("first_name", "must have at most %d characters", maxNameLength)
+	}
+	if utf8.RuneCountInString(req.GetLastName()) > maxNameLength {
+		vErr.Add("last_name", "must have at most %d characters", maxNameLength)
+	}
+
+	fields := req.GetFields()
+	if len(fields) == 0 {
+		fields = allFields
+	}
+	for _, f := range fields {
+		if !slices.Contains(allFields, f) {
+			vErr.Add("fields", "invalid field option %s", f)
+		}
+	}
+	if vErr.HasErrors() {
+		return toStatus(&vErr)
+	}
+
+	users, err := s.search.Execute(stream.Context(), usecase.SearchInput{
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+		Email:     req.GetEmailAddress(),
+		Fields:    strings.Join(fields, ","),
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+
+	for i := range users {
+		if err := stream.Send(newUser(&users[i], fields)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *userService) WatchUsers(req *userpb.WatchUsersRequest, stream userpb.UserService_WatchUsersServer) error {
+	err := s.watch.Execute(stream.Context(), usecase.WatchFilter{IDs: req.GetIds()}, func(change entity.UserChange) error {
+		// the stream cannot resume, the client watches again
+		// and reads its users again
+		if change.Type == entity.ChangesLost {
+			return status.Error(codes.Unavailable, "changes were lost, watch again")
+		}
+		return stream.Send(&userpb.UserChange{
+			Type: changeTypes[change.Type],
+			User: newUser(&change.User, allFields),
+			At:   timestamppb.New(change.At),
+		})
+	})
+	if err != nil {
+		return toStatus(err)
+	}
+	return nil
+}
+
+var changeTypes = map[entity.UserChangeType]userpb.UserChange_Type{
+	entity.UserCreated: userpb.UserChange_TYPE_CREATED,
+	entity.UserUpdated: userpb.UserChange_TYPE_UPDATED,
+	entity.UserDeleted: userpb.UserChange_TYPE_DELETED,
+	entity.UserMerged:  userpb.UserChange_TYPE_MERGED,
+}
+
+// newUser converts user keeping the selected fields only
+func newUser(user *entity.User, fields []string) *userpb.User {
+	u := &userpb.User{Version: user.Version}
+	for _, f := range fields {
+		switch f {
+		case "id":
+			u.Id = user.ID
+		case "first_name":
+			u.FirstName = user.FirstName
+		case "last_name":
+			u.LastName = user.LastName
+		case "email_address":
+			u.EmailAddress = user.Email
+		case "parent_user_id":
+			u.ParentUserId = user.ParentUserID
+		case "created_at":
+			u.CreatedAt = timestamp(&user.CreatedAt)
+		case "deleted_at":
+			u.DeletedAt = timestamp(user.DeletedAt)
+		case "merged_at":
+			u.MergedAt = timestamp(user.MergedAt)
+		}
+	}
+	return u
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
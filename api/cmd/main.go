@@ -2,95 +2,228 @@ package main
 
 import (
 	"api/cmd/gin/middleware"
+	"api/cmd/gin/openapi"
 	"api/cmd/gin/router"
+	"api/cmd/graph"
+	grpcservice "api/cmd/grpc/service"
+	"api/cmd/grpc/userpb"
 	"api/internal/adapter"
+	"api/internal/auth"
+	"api/internal/broadcast"
+	"api/internal/config"
+	"api/internal/logger"
+	"api/internal/metrics"
 	"api/internal/service"
 	"api/internal/usecase"
 	"context"
 	"database/sql"
-	"fmt"
+	"desafio/pkg/producer"
+	producerservice "desafio/pkg/service"
+	"desafio/pkg/tracing"
 	"log"
 	"log/slog"
+	"net"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"google.golang.org/grpc"
 )
 
+const consumerRetryInterval = 5 * time.Second
+
 func main() {
-	err := godotenv.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintRequested() {
+		cfg.Print(os.Stdout)
+	}
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("invalid config:\n%v", err)
+	}
+	if cfg.PrintRequested() {
+		return
 	}
 
-	var (
-		ctx = context.Background()
-
-		// API configs
-		apiPort = os.Getenv("API_PORT")
-
-		// 32 bytes hex key
-		cryptorKey = os.Getenv("CRYPTOR_KEY")
-
-		// Database config
-		dbHost   = os.Getenv("DB_HOST")
-		dbPort   = os.Getenv("DB_PORT")
-		dbUser   = os.Getenv("DB_USER")
-		dbPass   = os.Getenv("DB_PASS")
-		dbName   = os.Getenv("DB_NAME")
-		dbDriver = os.Getenv("DB_DRIVER")
-
-		// Cache config
-		cacheURL  = os.Getenv("CACHE_URL")
-		cachePass = os.Getenv("CACHE_PASS")
+	ctx := context.Background()
 
-		// AMQP config
-		amqpURL   = os.Getenv("AMQP_URL")
-		amqpQueue = os.Getenv("AMQP_QUEUE")
-	)
+	jsonLogger, err := logger.New(os.Stdout, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("error when try to create the logger: %v", err.Error())
+	}
+	slog.SetDefault(jsonLogger)
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	slog.SetDefault(logger)
+	shutdownTracing, err := tracing.Setup(ctx, "api", cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("error when try to setup tracing: %v", err.Error())
+	}
+	defer shutdownTracing(ctx)
 
-	datasource := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", dbHost, dbPort, dbUser, dbPass, dbName)
-	db, err := sql.Open(dbDriver, datasource)
+	db, err := sql.Open(cfg.DBDriver, cfg.Datasource())
 	if err != nil {
 		log.Fatalf("error when try to open a database conection: %v", err.Error())
 	}
 	defer db.Close()
+	db.SetMaxOpenConns(cfg.DBMaxConns)
+	db.SetMaxIdleConns(cfg.DBMaxConns)
+	metrics.RegisterDB(db, cfg.DBName)
 
-	redisAdapter := adapter.NewRedisCache(ctx, cacheURL, cachePass, 0)
+	redisAdapter := adapter.NewRedisCache(ctx, cfg.CacheURL, cfg.CachePass, 0)
 	defer redisAdapter.Close()
 
 	postgresAdapter := adapter.NewPostgreAdapter(db)
-	rabbitmqAdapter, err := adapter.NewRabbitMQAdapter(amqpURL, amqpQueue)
+	rabbitmqAdapter, err := adapter.NewRabbitMQAdapter(cfg.AMQPURL, cfg.AMQPQueue)
+	if err != nil {
+		log.Fatalf("error when try to open a mqp conection: %v", err.Error())
+	}
+	defer rabbitmqAdapter.Close()
+	metrics.RegisterQueueDepth(rabbitmqAdapter.Messages)
+
+	// imports publish through the producer adapter, like the producer does
+	importQueue, err := producer.NewRabbitMQAdapter(cfg.AMQPURL, cfg.AMQPQueue)
+	if err != nil {
+		log.Fatalf("error when try to open a mqp conection: %v", err.Error())
+	}
+	defer importQueue.Close()
+
+	eventPublisher, err := adapter.NewRabbitMQPublisher(cfg.AMQPURL, cfg.EventsExchange)
+	if err != nil {
+		log.Fatalf("error when try to open a mqp conection: %v", err.Error())
+	}
+	defer eventPublisher.Close()
+
+	eventSubscriber, err := adapter.NewRabbitMQSubscriber(cfg.AMQPURL, cfg.EventsExchange)
 	if err != nil {
 		log.Fatalf("error when try to open a mqp conection: %v", err.Error())
 	}
+	defer eventSubscriber.Close()
+
+	cryptor, err := service.NewUserCryptor(cfg.CryptorKey)
+	if err != nil {
+		log.Fatalf("error when try to create cryptor service: %v", err.Error())
+	}
 
-	cryptor, err := service.NewUserCryptor(cryptorKey)
+	// the same cryptor used by the producer, so imports go through its pipeline unchanged
+	importCryptor, err := producerservice.NewCryptor(cfg.CryptorKey)
 	if err != nil {
 		log.Fatalf("error when try to create cryptor service: %v", err.Error())
 	}
 
-	upsertUsecase := usecase.NewUpsertUsecase(rabbitmqAdapter, postgresAdapter, redisAdapter)
+	apiKeys, err := auth.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		log.Fatalf("error when try to load the api keys: %v", err.Error())
+	}
+
+	var jwtVerifier *auth.JWTVerifier
+	if cfg.JWKSFile != "" {
+		jwtVerifier, err = auth.NewJWTVerifier(cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience)
+		if err != nil {
+			log.Fatalf("error when try to load the JWKS: %v", err.Error())
+		}
+	}
+
+	rateLimits, err := middleware.ParseRateLimits(cfg.RateLimits)
+	if err != nil {
+		log.Fatalf("error when try to load the rate limits: %v", err.Error())
+	}
+	ipRateLimit, err := middleware.ParseRateLimit(cfg.IPRateLimit)
+	if err != nil {
+		log.Fatalf("error when try to load the IP rate limit: %v", err.Error())
+	}
+
+	doc, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("error when try to load the OpenAPI document: %v", err.Error())
+	}
+	spec, err := doc.MarshalJSON()
+	if err != nil {
+		log.Fatalf("error when try to load the OpenAPI document: %v", err.Error())
+	}
+
+	// changes applied by every instance, for the watchers
+	hub := broadcast.NewHub()
+
+	upsertUsecase := usecase.NewUpsertUsecase(rabbitmqAdapter, postgresAdapter, redisAdapter, redisAdapter, cfg.DedupTTL, cfg.Workers())
 	getByIDUsecase := usecase.NewGetByIDUsecase(postgresAdapter, redisAdapter, cryptor)
 	searchUsecase := usecase.NewSearchUsecase(postgresAdapter, redisAdapter, cryptor)
+	createUsecase := usecase.NewCreateUsecase(postgresAdapter, redisAdapter, cryptor)
+	updateUsecase := usecase.NewUpdateUsecase(postgresAdapter, redisAdapter, cryptor)
+	deleteUsecase := usecase.NewDeleteUsecase(postgresAdapter, redisAdapter)
+	mergeUsecase := usecase.NewMergeUsecase(postgresAdapter, redisAdapter, cryptor)
+	importUsecase := usecase.NewImportUsecase(importQueue, importCryptor, redisAdapter)
+	getImportUsecase := usecase.NewGetImportUsecase(redisAdapter)
+	exportUsecase := usecase.NewExportUsecase(postgresAdapter, cryptor)
+	watchUsecase := usecase.NewWatchUsecase(hub, cryptor)
+	relativesUsecase := usecase.NewRelativesUsecase(postgresAdapter, cryptor)
+	outboxRelayUsecase := usecase.NewOutboxRelayUsecase(postgresAdapter, eventPublisher)
+	broadcastUsecase := usecase.NewBroadcastUsecase(eventSubscriber, hub)
+
+	graphQLServer, err := graph.NewServer(getByIDUsecase, searchUsecase, relativesUsecase, graph.Limits{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	})
+	if err != nil {
+		log.Fatalf("error when try to build the GraphQL schema: %v", err.Error())
+	}
 
+	// a stopped consumer is reported by the readiness check, and
+	// retried until the queue connection is dialed again, instead
+	// of taking the api down
 	go func() {
 		for {
 			if err := upsertUsecase.Execute(ctx); err != nil {
-				log.Fatalf("error when try to open the message broker conection: %v", err.Error())
+				slog.ErrorContext(ctx, "error when try to consume from the message broker", "error", err)
+			}
+			time.Sleep(consumerRetryInterval)
+		}
+	}()
+
+	// events stay in the outbox while the broker is unavailable
+	go func() {
+		for {
+			if err := outboxRelayUsecase.Execute(ctx); err != nil {
+				slog.ErrorContext(ctx, "error when try to publish the user events", "error", err)
+			}
+			time.Sleep(consumerRetryInterval)
+		}
+	}()
+
+	// the watchers are told the changes missed until it subscribes again
+	go func() {
+		for {
+			if err := broadcastUsecase.Execute(ctx); err != nil {
+				slog.ErrorContext(ctx, "error when try to receive the user events", "error", err)
 			}
+			time.Sleep(consumerRetryInterval)
 		}
 	}()
 
 	server := gin.New()
-	server.Use(middleware.Error())
-	api := server.Group("/api")
+	server.Use(
+		middleware.RequestID(),
+		middleware.Tracing(),
+		middleware.AccessLog(),
+		middleware.Metrics(),
+		middleware.Error(),
+	)
+
+	metricsRouter := router.NewMetricsRouter()
+	metricsRouter.MetricsRouter(&server.RouterGroup)
+
+	authenticator := auth.NewAuthenticator(apiKeys, jwtVerifier)
+
+	// routes declare the scopes they require, health stays open
+	api := server.Group("/api",
+		middleware.IPRateLimiter(redisAdapter, ipRateLimit),
+		middleware.Authenticate(authenticator),
+		middleware.RateLimiter(redisAdapter, rateLimits),
+		middleware.ValidateRequests(doc, "/api"),
+	)
+
+	openAPIRouter := router.NewOpenAPIRouter(spec, openapi.DocsHTML)
+	openAPIRouter.OpenAPIRouter(api)
 
-	healthRouter := router.NewHealthRouter(db, redisAdapter)
+	healthRouter := router.NewHealthRouter(db, redisAdapter, rabbitmqAdapter, eventPublisher, upsertUsecase)
 	healthRouter.HealthRouter(api)
 
 	getByIdRouter := router.NewGetByIDRouter(getByIDUsecase)
@@ -99,7 +232,70 @@ func main() {
 	searchRouter := router.NewSearchRouter(searchUsecase)
 	searchRouter.SearchRouter(api)
 
-	if err := server.Run(apiPort); err != nil {
+	createRouter := router.NewCreateRouter(createUsecase)
+	createRouter.CreateRouter(api)
+
+	updateRouter := router.NewUpdateRouter(updateUsecase)
+	updateRouter.UpdateRouter(api)
+
+	deleteRouter := router.NewDeleteRouter(deleteUsecase)
+	deleteRouter.DeleteRouter(api)
+
+	mergeRouter := router.NewMergeRouter(mergeUsecase)
+	mergeRouter.MergeRouter(api)
+
+	importRouter := router.NewImportRouter(importUsecase)
+	importRouter.ImportRouter(api)
+
+	getImportRouter := router.NewGetImportRouter(getImportUsecase)
+	getImportRouter.GetImportRouter(api)
+
+	exportRouter := router.NewExportRouter(exportUsecase)
+	exportRouter.ExportRouter(api)
+
+	streamRouter := router.NewStreamRouter(watchUsecase)
+	streamRouter.StreamRouter(api)
+
+	graphQLRouter := router.NewGraphQLRouter(graphQLServer)
+	graphQLRouter.GraphQLRouter(api)
+
+	// every route must be documented, and every documented operation served
+	if err := openapi.CheckRoutes(doc, "/api", server.Routes()); err != nil {
+		log.Fatalf("the OpenAPI document does not match the routes: %v", err.Error())
+	}
+
+	grpcScopes := grpcservice.MethodScopes{
+		userpb.UserService_GetUser_FullMethodName:     {auth.ScopeUsersRead},
+		userpb.UserService_SearchUsers_FullMethodName: {auth.ScopeUsersRead},
+		userpb.UserService_WatchUsers_FullMethodName:  {auth.ScopeUsersRead},
+	}
+	// the IP limit runs before authenticating, like in the HTTP api
+	unaryInterceptors := []grpc.UnaryServerInterceptor{grpcservice.RecoveryUnaryInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{grpcservice.RecoveryStreamInterceptor()}
+	if ipRateLimit != nil {
+		unaryInterceptors = append(unaryInterceptors, grpcservice.IPRateLimitUnaryInterceptor(redisAdapter, ipRateLimit.Rate, ipRateLimit.Burst))
+		streamInterceptors = append(streamInterceptors, grpcservice.IPRateLimitStreamInterceptor(redisAdapter, ipRateLimit.Rate, ipRateLimit.Burst))
+	}
+	unaryInterceptors = append(unaryInterceptors, grpcservice.AuthUnaryInterceptor(authenticator, grpcScopes))
+	streamInterceptors = append(streamInterceptors, grpcservice.AuthStreamInterceptor(authenticator, grpcScopes))
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	userpb.RegisterUserServiceServer(grpcServer, grpcservice.NewUserService(getByIDUsecase, searchUsecase, watchUsecase))
+
+	grpcListener, err := net.Listen("tcp", cfg.GRPCPort)
+	if err != nil {
+		log.Fatalf("error when try to listen for gRPC: %v", err.Error())
+	}
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("error when try to run the gRPC api: %v", err.Error())
+		}
+	}()
+
+	if err := server.Run(cfg.APIPort); err != nil {
 		log.Fatalf("error when try to run the api: %v", err.Error())
 	}
 }
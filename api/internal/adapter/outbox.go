@@ -0,0 +1,170 @@
+package adapter
+
+import (
+	"api/internal/entity"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// eventPayload is the body of the published events, the user is
+// sent as stored, with its email encrypted
+type eventPayload struct {
+	Type       entity.UserChangeType `json:"type"`
+	OccurredAt time.Time             `json:"occurred_at"`
+	User       eventUser             `json:"user"`
+}
+
+type eventUser struct {
+	ID           int64      `json:"id"`
+	FirstName    string     `json:"first_name"`
+	LastName     string     `json:"last_name"`
+	Email        string     `json:"email_address"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+	MergedAt     *time.Time `json:"merged_at"`
+	ParentUserID *int64     `json:"parent_user_id"`
+	Version      int64      `json:"version"`
+}
+
+// changeType tells the event of a write from the user before it,
+// nil if it did not exist, and after it. Returns false when the
+// write changed nothing
+func changeType(previous *entity.User, current entity.User) (entity.UserChangeType, bool) {
+	switch {
+	case previous == nil:
+		return entity.UserCreated, true
+	case current.Version == previous.Version:
+		return "", false
+	case previous.DeletedAt == nil && current.DeletedAt != nil:
+		return entity.UserDeleted, true
+	case previous.MergedAt == nil && current.MergedAt != nil:
+		return entity.UserMerged, true
+	}
+	return entity.UserUpdated, true
+}
+
+// insertEvent records the event in the outbox within tx, so it is
+// only published if the write it describes is committed
+func insertEvent(ctx context.Context, tx *sql.Tx, changeType entity.UserChangeType, user entity.User) error {
+	payload, err := json.Marshal(eventPayload{
+		Type:       changeType,
+		OccurredAt: time.Now().UTC(),
+		User:       eventUser(user),
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO challenge.user_events (type, user_id, payload, headers)
+	VALUES ($1, $2, $3, $4);
+	`, changeType, user.ID, payload, encodedHeaders)
+	return err
+}
+
+// lockUser selects the user matching column for update within tx,
+// or nil if it does not exist
+func lockUser(ctx context.Context, tx *sql.Tx, column string, value any) (*entity.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx, `
+	SELECT `+userColumns+`
+	FROM challenge.users
+	WHERE `+column+` = $1
+	FOR UPDATE;
+	`, value))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// outboxLockKey serializes the relays of the instances, so the
+// events are published by one of them at a time, in order
+const outboxLockKey = 7262002
+
+// PublishEvents hands the oldest events of the outbox, at most
+// limit, to fn in the order of their ids and removes the ones it
+// accepted. It stops at the first error, so a failed event is not
+// overtaken by the next ones. Only one instance publishes at a time,
+// the others return 0 until it is done
+func (a *postgresAdapter) PublishEvents(ctx context.Context, limit int, fn func(event entity.UserEvent) error) (_ int, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.PublishEvents")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1);", outboxLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, type, user_id, payload, headers
+	FROM challenge.user_events
+	ORDER BY id
+	LIMIT $1;
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []entity.UserEvent
+	for rows.Next() {
+		var (
+			event   entity.UserEvent
+			headers []byte
+		)
+		if err := rows.Scan(&event.ID, &event.Type, &event.UserID, &event.Payload, &headers); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := json.Unmarshal(headers, &event.Headers); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	published := make([]int64, 0, len(events))
+	var fnErr error
+	for _, event := range events {
+		if fnErr = fn(event); fnErr != nil {
+			break
+		}
+		published = append(published, event.ID)
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM challenge.user_events WHERE id = ANY($1);", pq.Array(published)); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(published), fnErr
+}
@@ -4,10 +4,41 @@ import (
 	"api/internal/entity"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+const userColumns = "id, first_name, last_name, email_address, created_at, deleted_at, merged_at, parent_user_id, version"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans a row selected with userColumns
+func scanUser(row rowScanner) (*entity.User, error) {
+	var user entity.User
+	err := row.Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Email,
+		&user.CreatedAt,
+		&user.DeletedAt,
+		&user.MergedAt,
+		&user.ParentUserID,
+		&user.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 type postgresAdapter struct {
 	db *sql.DB
 }
@@ -18,7 +49,26 @@ func NewPostgreAdapter(db *sql.DB) *postgresAdapter {
 	}
 }
 
-func (a *postgresAdapter) Upsert(ctx context.Context, user entity.User) error {
+// Upsert stores the user, keeping the stored one when it is newer,
+// and records the resulting event in the outbox. Returns the stored
+// user and whether the write changed it
+func (a *postgresAdapter) Upsert(ctx context.Context, user entity.User) (_ *entity.User, changed bool, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Upsert")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// a user inserted concurrently is not locked, and its
+	// update below is reported as a creation
+	previous, err := lockUser(ctx, tx, "email_address", user.Email)
+	if err != nil {
+		return nil, false, err
+	}
+
 	query := `
 	INSERT INTO challenge.users (id, first_name, last_name, email_address, created_at, deleted_at, merged_at, parent_user_id)
 	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
@@ -29,10 +79,12 @@ func (a *postgresAdapter) Upsert(ctx context.Context, user entity.User) error {
 		created_at = CASE WHEN EXCLUDED.created_at > users.created_at THEN EXCLUDED.created_at ELSE users.created_at END,
 		deleted_at = CASE WHEN EXCLUDED.created_at > users.created_at THEN EXCLUDED.deleted_at ELSE users.deleted_at END,
 		merged_at = CASE WHEN EXCLUDED.created_at > users.created_at THEN EXCLUDED.merged_at ELSE users.merged_at END,
-		parent_user_id = CASE WHEN EXCLUDED.created_at > users.created_at THEN EXCLUDED.parent_user_id ELSE users.parent_user_id END
-	RETURNING id;
+		parent_user_id = CASE WHEN EXCLUDED.created_at > users.created_at THEN EXCLUDED.parent_user_id ELSE users.parent_user_id END,
+		version = CASE WHEN EXCLUDED.created_at > users.created_at THEN users.version + 1 ELSE users.version END
+	RETURNING ` + userColumns + `;
     `
-	_, err := a.db.Exec(
+	current, err := scanUser(tx.QueryRowContext(
+		ctx,
 		query,
 		user.ID,
 		user.FirstName,
@@ -42,37 +94,317 @@ func (a *postgresAdapter) Upsert(ctx context.Context, user entity.User) error {
 		user.DeletedAt,
 		user.MergedAt,
 		user.ParentUserID,
-	)
+	))
+	if err != nil {
+		return nil, false, err
+	}
+
+	changeType, changed := changeType(previous, *current)
+	if changed {
+		if err := insertEvent(ctx, tx, changeType, *current); err != nil {
+			return nil, false, err
+		}
+	}
 
-	return err
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return current, changed, nil
 }
 
-func (a *postgresAdapter) GetByID(ctx context.Context, id string) (*entity.User, error) {
+// foreignKeyViolation is the code of the errors raised by a
+// parent_user_id that matches no user
+const foreignKeyViolation = "23503"
+
+// Create inserts the user and records its creation in the outbox.
+// Returns a business error if the id or the email is taken, and a
+// validation error if the parent does not exist
+func (a *postgresAdapter) Create(ctx context.Context, user entity.User) (_ *entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Create")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
-    SELECT id, first_name, last_name, email_address, created_at, deleted_at, merged_at, parent_user_id
+	INSERT INTO challenge.users (id, first_name, last_name, email_address, created_at, deleted_at, merged_at, parent_user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT DO NOTHING
+	RETURNING ` + userColumns + `;
+	`
+	created, err := scanUser(tx.QueryRowContext(
+		ctx,
+		query,
+		user.ID,
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		user.CreatedAt,
+		user.DeletedAt,
+		user.MergedAt,
+		user.ParentUserID,
+	))
+	var pqErr *pq.Error
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, entity.NewBusinessError("user %d already exists", user.ID)
+	case errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation:
+		var vErr entity.ValidationError
+		vErr.Add("parent_user_id", "user %d does not exist", *user.ParentUserID)
+		return nil, &vErr
+	case err != nil:
+		return nil, err
+	}
+
+	if err := insertEvent(ctx, tx, entity.UserCreated, *created); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func (a *postgresAdapter) GetByID(ctx context.Context, id string) (_ *entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.GetByID")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+    SELECT ` + userColumns + `
     FROM challenge.users
     WHERE id = $1;
     `
 
-	var user entity.User
-	err := a.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.FirstName,
-		&user.LastName,
-		&user.Email,
-		&user.CreatedAt,
-		&user.DeletedAt,
-		&user.MergedAt,
-		&user.ParentUserID,
-	)
-
+	user, err := scanUser(a.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+// GetByIDs returns the users with the given ids, missing ids
+// are skipped
+func (a *postgresAdapter) GetByIDs(ctx context.Context, ids []int64) (_ []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.GetByIDs")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	SELECT ` + userColumns + `
+	FROM challenge.users
+	WHERE id = ANY($1);
+	`
+
+	return a.queryUsers(ctx, query, pq.Array(ids))
+}
+
+// GetChildren returns the users whose parent is one of parentIDs
+func (a *postgresAdapter) GetChildren(ctx context.Context, parentIDs []int64) (_ []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.GetChildren")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+	SELECT ` + userColumns + `
+	FROM challenge.users
+	WHERE parent_user_id = ANY($1)
+	ORDER BY id;
+	`
+
+	return a.queryUsers(ctx, query, pq.Array(parentIDs))
+}
+
+func (a *postgresAdapter) queryUsers(ctx context.Context, query string, args ...any) ([]entity.User, error) {
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+// Update overwrites the mutable fields of the given user and returns
+// the stored result, or nil if the user does not exist. When version
+// is not nil the update only happens if it matches the stored version
+func (a *postgresAdapter) Update(ctx context.Context, user entity.User, version *int64) (_ *entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Update")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	previous, err := lockUser(ctx, tx, "id", user.ID)
+	if err != nil || previous == nil {
+		return nil, err
+	}
+	if version != nil && *version != previous.Version {
+		return nil, entity.NewPreconditionError("user %d was modified, current version is %d", previous.ID, previous.Version)
+	}
+
+	query := `
+	UPDATE challenge.users
+	SET first_name = $2, last_name = $3, email_address = $4, deleted_at = $5, version = version + 1
+	WHERE id = $1
+	RETURNING ` + userColumns + `;
+	`
+
+	updated, err := scanUser(tx.QueryRowContext(
+		ctx,
+		query,
+		user.ID,
+		user.FirstName,
+		user.LastName,
+		user.Email,
+		user.DeletedAt,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if changeType, ok := changeType(previous, *updated); ok {
+		if err := insertEvent(ctx, tx, changeType, *updated); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// mergeLockKey serializes merges so concurrent merges cannot
+// create a cycle that neither of them sees on its own
+const mergeLockKey = 7262001
+
+// Merge marks the user as merged into target, re-pointing the
+// children of the merged user to target. It returns the merged user
+// and the ids of the re-pointed children, or nil if the user does
+// not exist
+func (a *postgresAdapter) Merge(ctx context.Context, id, targetID int64) (_ *entity.User, _ []int64, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Merge")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1);", mergeLockKey); err != nil {
+		return nil, nil, err
+	}
+
+	var mergedAt *time.Time
+	err = tx.QueryRowContext(ctx, "SELECT merged_at FROM challenge.users WHERE id = $1 FOR UPDATE;", id).Scan(&mergedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if mergedAt != nil {
+		return nil, nil, entity.NewBusinessError("user %d is already merged", id)
+	}
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM challenge.users WHERE id = $1);", targetID).Scan(&exists)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, entity.NewBusinessError("target user %d does not exist", targetID)
+	}
+
+	// walks up the parents of target, merging into a descendant
+	// (or into itself) would create a cycle
+	cycleQuery := `
+	WITH RECURSIVE ancestors (id, parent_user_id) AS (
+		SELECT id, parent_user_id FROM challenge.users WHERE id = $1
+		UNION
+		SELECT u.id, u.parent_user_id FROM challenge.users u
+		JOIN ancestors a ON u.id = a.parent_user_id
+	)
+	SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2);
+	`
+	var cycle bool
+	if err := tx.QueryRowContext(ctx, cycleQuery, targetID, id).Scan(&cycle); err != nil {
+		return nil, nil, err
+	}
+	if cycle {
+		return nil, nil, entity.NewBusinessError("merging user %d into %d would create a cycle", id, targetID)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE challenge.users
+	SET parent_user_id = $2, version = version + 1
+	WHERE parent_user_id = $1
+	RETURNING `+userColumns+`;
+	`, id, targetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var movedChildren []entity.User
+	for rows.Next() {
+		child, err := scanUser(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		movedChildren = append(movedChildren, *child)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+
+	children := make([]int64, 0, len(movedChildren))
+	for _, child := range movedChildren {
+		if err := insertEvent(ctx, tx, entity.UserUpdated, child); err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child.ID)
+	}
+
+	merged, err := scanUser(tx.QueryRowContext(ctx, `
+	UPDATE challenge.users
+	SET merged_at = NOW(), parent_user_id = $2, version = version + 1
+	WHERE id = $1
+	RETURNING `+userColumns+`;
+	`, id, targetID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := insertEvent(ctx, tx, entity.UserMerged, *merged); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return merged, children, nil
 }
 
 type QueryOpts struct {
@@ -83,15 +415,106 @@ type QueryOpts struct {
 	Fields string
 }
 
-func (a *postgresAdapter) Search(ctx context.Context, queryOpts QueryOpts) ([]entity.User, error) {
+func (a *postgresAdapter) Search(ctx context.Context, queryOpts QueryOpts) (_ []entity.User, err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Search")
+	defer func() { endSpan(span, err) }()
+
 	baseQuery := `
-	   SELECT id, first_name, last_name, email_address, created_at, deleted_at, merged_at, parent_user_id
+	   SELECT ` + userColumns + `
 	   FROM challenge.users
 	   `
 	// baseQuery := fmt.Sprintf("SELECT %s FROM users\n", queryOpts.Fields)
 
+	where, args := whereClause(queryOpts)
+	baseQuery += where
+
+	rows, err := a.db.QueryContext(ctx, baseQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entity.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+// exportFetchSize is the number of rows fetched from the cursor at time
+const exportFetchSize = 500
+
+// Export calls fn for every user matching queryOpts. Rows are read
+// through a server side cursor so the result is never fully loaded
+// in memory. Returning an error from fn stops the export
+func (a *postgresAdapter) Export(ctx context.Context, queryOpts QueryOpts, fn func(user entity.User) error) (err error) {
+	ctx, span := tracer.Start(ctx, "postgres.Export")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := a.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	where, args := whereClause(queryOpts)
+	query := `
+	DECLARE export_cursor NO SCROLL CURSOR FOR
+	SELECT ` + userColumns + `
+	FROM challenge.users
+	` + where + `
+	ORDER BY id;
+	`
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor;", exportFetchSize)
+	for {
+		n, err := fetchUsers(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if n < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+// fetchUsers runs a single FETCH, returning the number of rows read
+func fetchUsers(ctx context.Context, tx *sql.Tx, fetch string, fn func(user entity.User) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return n, err
+		}
+		if err := fn(*user); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, rows.Err()
+}
+
+// whereClause builds the filters shared by Search and Export
+func whereClause(queryOpts QueryOpts) (string, []any) {
 	conditions := []string{}
-	args := []interface{}{}
+	args := []any{}
 	argID := 1
 
 	if queryOpts.FirstName != "" {
@@ -110,35 +533,10 @@ func (a *postgresAdapter) Search(ctx context.Context, queryOpts QueryOpts) ([]en
 		argID++
 	}
 
-	if len(conditions) > 0 {
-		baseQuery += "WHERE " + strings.Join(conditions, " AND ")
-	}
-
-	fmt.Println(baseQuery)
-
-	rows, err := a.db.Query(baseQuery, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	fmt.Println(rows)
-
-	var users []entity.User
-	for rows.Next() {
-		var user entity.User
-		if err := rows.Scan(
-			// scanFields(&user, strings.Split(queryOpts.Fields, ",")...),
-			&user.ID, &user.FirstName, &user.LastName,
-			&user.Email, &user.CreatedAt,
-			&user.DeletedAt, &user.MergedAt, &user.ParentUserID,
-		); err != nil {
-			return nil, err
-		}
-		users = append(users, user)
+	if len(conditions) == 0 {
+		return "", args
 	}
-
-	return users, nil
+	return "WHERE " + strings.Join(conditions, " AND "), args
 }
 
 // func scanFields(user *entity.User, fields ...string) []interface{} {
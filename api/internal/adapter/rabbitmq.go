@@ -1,90 +1,170 @@
 package adapter
 
 import (
+	"desafio/pkg/rabbitmq"
 	"fmt"
 
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// Message is a message consumed from the queue
+type Message struct {
+	// ID is the message id set by the producer, empty if none
+	ID   string
+	Body string
+	// Headers holds the string headers of the message,
+	// such as the propagated trace context
+	Headers map[string]string
+	// Redelivered is set when the message was delivered before
+	// without being acknowledged
+	Redelivered bool
+
+	delivery *amqp.Delivery
+}
+
+// Ack tells the broker the message was processed, removing it
+// from the queue
+func (m Message) Ack() error {
+	if m.delivery == nil {
+		return nil
+	}
+	return m.delivery.Ack(false)
+}
+
+// Nack tells the broker the message failed. It is put back in the
+// queue when requeue is set, otherwise it is dropped or dead lettered
+func (m Message) Nack(requeue bool) error {
+	if m.delivery == nil {
+		return nil
+	}
+	return m.delivery.Nack(false, requeue)
+}
+
 // rabbitMQAdapter defines an adapter for RabbitMQ
 type rabbitmqAdapter struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	queue   amqp.Queue
+	queue string
+	conn  *rabbitmq.Connection
 }
 
 // NewRabbitMQAdapter creates a RabbitMQ adapter
 // returns an error if any issue connectin to the
-// server occours
+// server occours. A lost connection is dialed again
+// in background until Close
 func NewRabbitMQAdapter(url, queeu string) (*rabbitmqAdapter, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-
-	channel, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to create RabbitMQ channel: %w", err)
-	}
-
-	queue, err := channel.QueueDeclare(queeu, true, false, false, false, nil)
+	conn, err := rabbitmq.NewConnection(url, func(channel *amqp.Channel) error {
+		if _, err := channel.QueueDeclare(queeu, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare RabbitMQ queue: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare RabbitMQ queue: %w", err)
+		return nil, err
 	}
 
 	return &rabbitmqAdapter{
-		conn:    conn,
-		channel: channel,
-		queue:   queue,
+		queue: queeu,
+		conn:  conn,
 	}, nil
 }
 
-// Publish sends a message to the queue
-func (r *rabbitmqAdapter) Publish(message, contentType string) error {
-	err := r.channel.Publish(
-		"",           // Exchange
-		r.queue.Name, // Routing key (queue name)
-		false,        // Mandatory
-		false,        // Immediate
-		amqp.Publishing{
-			ContentType: contentType,
-			Body:        []byte(message),
-		})
-	if err != nil {
-		return fmt.Errorf("failed to publish message: %w", err)
+// Consume starts consuming messages from queue, at most prefetch
+// of them being unacknowledged at a time. Every message must be
+// acknowledged with Ack or Nack, the ones left when the connection
+// closes are delivered again. It consumes from the channel in use,
+// so once the connection is dialed again Consume must be called again
+func (r *rabbitmqAdapter) Consume(ch chan<- Message, prefetch int) error {
+	channel := r.conn.Channel()
+	if err := channel.Qos(prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set RabbitMQ prefetch: %w", err)
 	}
-	return nil
-}
 
-// Consume starts consuming messages from queue
-func (r *rabbitmqAdapter) Consume(ch chan<- string) error {
-	msgs, err := r.channel.Consume(
-		r.queue.Name, // Queue name
-		"",           // Consumer
-		true,         // Auto-ack
-		false,        // Exclusive
-		false,        // No-local
-		false,        // No-wait
-		nil,          // Arguments
+	msgs, err := channel.Consume(
+		r.queue, // Queue name
+		"",      // Consumer
+		false,   // Auto-ack
+		false,   // Exclusive
+		false,   // No-local
+		false,   // No-wait
+		nil,     // Arguments
 	)
 	if err != nil {
 		return fmt.Errorf("failed to start consuming messages: %w", err)
 	}
 
 	go func() {
+		// the deliveries end when the connection or the channel is
+		// closed, closing ch lets the consumer know it has stopped
+		defer close(ch)
 		for m := range msgs {
-			ch <- string(m.Body)
+			ch <- Message{
+				ID:          m.MessageId,
+				Body:        string(m.Body),
+				Headers:     stringHeaders(m.Headers),
+				Redelivered: m.Redelivered,
+				delivery:    &m,
+			}
 		}
 	}()
 
 	return nil
 }
 
-// Close closes the connection and channel to RabbitMQ
+// Status returns an error if the connection or the channel is
+// closed, which lasts until it is dialed again
+func (r *rabbitmqAdapter) Status() error {
+	return r.conn.Status()
+}
+
+// Messages returns the number of messages ready in the queue. It
+// uses a short lived channel since a failed passive declare closes
+// the channel it runs on
+func (r *rabbitmqAdapter) Messages() (int, error) {
+	channel, err := r.conn.Conn().Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create RabbitMQ channel: %w", err)
+	}
+	defer channel.Close()
+
+	queue, err := channel.QueueDeclarePassive(r.queue, true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect RabbitMQ queue: %w", err)
+	}
+	return queue.Messages, nil
+}
+
+// stringHeaders keeps the headers holding string values
+func stringHeaders(table amqp.Table) map[string]string {
+	headers := make(map[string]string, len(table))
+	for k, v := range table {
+		if str, ok := v.(string); ok {
+			headers[k] = str
+		}
+	}
+	return headers
+}
+
+// headerCarrier adapts AMQP headers to carry the trace context
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Close closes the connection and channel to RabbitMQ, and stops
+// dialing again
 func (r *rabbitmqAdapter) Close() {
-	r.channel.Close()
 	r.conn.Close()
 }
@@ -0,0 +1,107 @@
+package adapter
+
+import (
+	"api/internal/entity"
+	"context"
+	"desafio/pkg/rabbitmq"
+	"fmt"
+	"strconv"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rabbitmqPublisher publishes the user events to a topic exchange,
+// routed by their type
+type rabbitmqPublisher struct {
+	exchange string
+	conn     *rabbitmq.Connection
+}
+
+// NewRabbitMQPublisher creates a publisher to the given topic
+// exchange, declaring it. Its channel is in confirm mode, so a
+// publish only succeeds once the broker took the event. A lost
+// connection is dialed again in background until Close, the
+// events published meanwhile fail and stay in the outbox
+func NewRabbitMQPublisher(url, exchange string) (*rabbitmqPublisher, error) {
+	conn, err := rabbitmq.NewConnection(url, func(channel *amqp.Channel) error {
+		if err := channel.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare RabbitMQ exchange: %w", err)
+		}
+		if err := channel.Confirm(false); err != nil {
+			return fmt.Errorf("failed to enable RabbitMQ publisher confirms: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rabbitmqPublisher{
+		exchange: exchange,
+		conn:     conn,
+	}, nil
+}
+
+// Publish sends the event with its type as routing key and waits
+// for the broker to confirm it. The outbox id is the message id,
+// consumers use it to drop the events published more than once
+func (r *rabbitmqPublisher) Publish(ctx context.Context, event entity.UserEvent) (err error) {
+	// continues the trace of the write that recorded the event
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(event.Headers))
+	ctx, span := tracer.Start(ctx, "rabbitmq.PublishEvent",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", r.exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", string(event.Type)),
+		))
+	defer func() { endSpan(span, err) }()
+
+	headers := amqp.Table{}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+
+	confirm, err := r.conn.Channel().PublishWithDeferredConfirmWithContext(
+		ctx,
+		r.exchange,
+		string(event.Type),
+		false,
+		false,
+		amqp.Publishing{
+			Headers:      headers,
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    strconv.FormatInt(event.ID, 10),
+			Type:         string(event.Type),
+			Timestamp:    time.Now().UTC(),
+			Body:         event.Payload,
+		})
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm event: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("event %d was rejected by the broker", event.ID)
+	}
+	return nil
+}
+
+// Status returns an error if the connection or the channel is
+// closed, which lasts until it is dialed again
+func (r *rabbitmqPublisher) Status() error {
+	return r.conn.Status()
+}
+
+// Close closes the connection and channel to RabbitMQ, and stops
+// dialing again
+func (r *rabbitmqPublisher) Close() {
+	r.conn.Close()
+}
@@ -0,0 +1,106 @@
+package adapter
+
+import (
+	"api/internal/entity"
+	"desafio/pkg/rabbitmq"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rabbitmqSubscriber receives the user events of a topic exchange,
+// every api instance through a queue of its own
+type rabbitmqSubscriber struct {
+	exchange string
+	conn     *rabbitmq.Connection
+}
+
+// NewRabbitMQSubscriber creates a subscriber to the given topic
+// exchange, declaring it. A lost connection is dialed again in
+// background until Close
+func NewRabbitMQSubscriber(url, exchange string) (*rabbitmqSubscriber, error) {
+	conn, err := rabbitmq.NewConnection(url, func(channel *amqp.Channel) error {
+		if err := channel.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare RabbitMQ exchange: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rabbitmqSubscriber{
+		exchange: exchange,
+		conn:     conn,
+	}, nil
+}
+
+// Subscribe binds an exclusive queue to every event of the exchange
+// and sends them to ch as user changes, with the outbox id of the
+// event as id. The queue is deleted once the connection closes, which
+// closes ch, the events published until Subscribe is called again on
+// the connection dialed again are lost
+func (r *rabbitmqSubscriber) Subscribe(ch chan<- entity.UserChange) error {
+	channel := r.conn.Channel()
+	queue, err := channel.QueueDeclare(
+		"",    // Name, chosen by the server
+		false, // Durable
+		true,  // Auto-delete
+		true,  // Exclusive
+		false, // No-wait
+		nil,   // Arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare RabbitMQ queue: %w", err)
+	}
+
+	if err := channel.QueueBind(queue.Name, "#", r.exchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind RabbitMQ queue: %w", err)
+	}
+
+	msgs, err := channel.Consume(
+		queue.Name, // Queue name
+		"",         // Consumer
+		true,       // Auto-ack
+		true,       // Exclusive
+		false,      // No-local
+		false,      // No-wait
+		nil,        // Arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming events: %w", err)
+	}
+
+	go func() {
+		defer close(ch)
+		for m := range msgs {
+			var payload eventPayload
+			if err := json.Unmarshal(m.Body, &payload); err != nil {
+				slog.Error("rabbitmq-subscriber", slog.Group("Subscribe", "unmarshal", err, "id", m.MessageId))
+				continue
+			}
+			ch <- entity.UserChange{
+				ID:   m.MessageId,
+				Type: payload.Type,
+				User: entity.User(payload.User),
+				At:   payload.OccurredAt,
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Status returns an error if the connection or the channel is
+// closed, which lasts until it is dialed again
+func (r *rabbitmqSubscriber) Status() error {
+	return r.conn.Status()
+}
+
+// Close closes the connection and channel to RabbitMQ, and stops
+// dialing again
+func (r *rabbitmqSubscriber) Close() {
+	r.conn.Close()
+}
@@ -1,7 +1,11 @@
 package adapter
 
 import (
+	"api/internal/metrics"
 	"context"
+	"fmt"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -25,22 +29,60 @@ func NewRedisCache(ctx context.Context, addr, password string, db int) *RedisCac
 }
 
 // Set stores a value in Redis with an optional expiration duration
-func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+func (r *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) (err error) {
+	ctx, span := tracer.Start(ctx, "redis.Set")
+	defer func() { endSpan(span, err) }()
+
 	return r.client.Set(ctx, key, value, ttl).Err()
 }
 
+// Exists reports whether the key is stored in Redis
+func (r *RedisCache) Exists(ctx context.Context, key string) (_ bool, err error) {
+	ctx, span := tracer.Start(ctx, "redis.Exists")
+	defer func() { endSpan(span, err) }()
+
+	n, err := r.client.Exists(ctx, key).Result()
+	return n > 0, err
+}
+
 // Get retrieves a value from Redis. Returns an error if the key does not exist
-func (r *RedisCache) Get(ctx context.Context, key string) (*string, error) {
+func (r *RedisCache) Get(ctx context.Context, key string) (_ *string, err error) {
+	ctx, span := tracer.Start(ctx, "redis.Get")
+	defer func() { endSpan(span, err) }()
+
 	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		metrics.CacheRequests.WithLabelValues("miss").Inc()
 		return nil, nil
 	} else if err != nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
+	metrics.CacheRequests.WithLabelValues("hit").Inc()
 	return &val, nil
 }
 
-func (r *RedisCache) Ping(ctx context.Context) error {
+// Incr increments the integer stored at key, a missing key counts
+// as 0, and returns its new value
+func (r *RedisCache) Incr(ctx context.Context, key string) (_ int64, err error) {
+	ctx, span := tracer.Start(ctx, "redis.Incr")
+	defer func() { endSpan(span, err) }()
+
+	return r.client.Incr(ctx, key).Result()
+}
+
+// Del removes the given keys from Redis, missing keys are ignored
+func (r *RedisCache) Del(ctx context.Context, keys ...string) (err error) {
+	ctx, span := tracer.Start(ctx, "redis.Del")
+	defer func() { endSpan(span, err) }()
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
+func (r *RedisCache) Ping(ctx context.Context) (err error) {
+	ctx, span := tracer.Start(ctx, "redis.Ping")
+	defer func() { endSpan(span, err) }()
+
 	return r.client.Ping(ctx).Err()
 }
 
@@ -48,3 +90,76 @@ func (r *RedisCache) Ping(ctx context.Context) error {
 func (r *RedisCache) Close() error {
 	return r.client.Close()
 }
+
+// tokenBucketScript refills the bucket at KEYS[1] by ARGV[1] tokens
+// per second up to ARGV[2] and takes one token when available. The
+// clock of the Redis server is used so every replica of the api
+// shares the same time
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = (1 - tokens) / rate
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens), tostring(retry), tostring((burst - tokens) / rate)}
+`)
+
+// TokenBucket is the state of a bucket after taking a token
+type TokenBucket struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is the wait for the next token when not allowed
+	RetryAfter time.Duration
+	// Reset is the wait until the bucket is full again
+	Reset time.Duration
+}
+
+// TakeToken takes a token from the bucket stored at key, which
+// holds up to burst tokens refilled at rate tokens per second
+func (r *RedisCache) TakeToken(ctx context.Context, key string, rate float64, burst int) (_ TokenBucket, err error) {
+	ctx, span := tracer.Start(ctx, "redis.TakeToken")
+	defer func() { endSpan(span, err) }()
+
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{key}, rate, burst).Slice()
+	if err != nil {
+		return TokenBucket{}, err
+	}
+	if len(res) != 4 {
+		return TokenBucket{}, fmt.Errorf("unexpected token bucket reply %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	tokens := parseFloat(res[1])
+	return TokenBucket{
+		Allowed:    allowed == 1,
+		Remaining:  int(math.Floor(tokens)),
+		RetryAfter: seconds(parseFloat(res[2])),
+		Reset:      seconds(parseFloat(res[3])),
+	}, nil
+}
+
+func parseFloat(v any) float64 {
+	s, _ := v.(string)
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"api/internal/auth"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// PIIRead records that the caller of ctx was shown the unmasked
+// personal data of the users. Records go to the default logger
+// with the "audit" message so they can be routed apart
+func PIIRead(ctx context.Context, action string, userIDs []int64) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	var subject, method string
+	if p := auth.PrincipalFrom(ctx); p != nil {
+		subject, method = p.Subject, p.Method
+	}
+
+	slog.LogAttrs(ctx, slog.LevelInfo, "audit",
+		slog.String("event", "pii_read"),
+		slog.String("action", action),
+		slog.String("subject", subject),
+		slog.String("auth_method", method),
+		slog.Any("user_ids", userIDs),
+		slog.Time("at", time.Now().UTC()),
+	)
+}
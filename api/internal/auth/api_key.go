@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type apiKey struct {
+	name   string
+	hash   []byte
+	scopes []string
+}
+
+// APIKeys authenticates static api keys, only the SHA-256 of
+// every key is kept
+type APIKeys struct {
+	keys []apiKey
+}
+
+// ParseAPIKeys parses a ";" separated list of keys, each one
+// made of whitespace separated fields: a name, the hex encoded
+// SHA-256 of the key and its scopes, e.g.
+//
+//	admin 5f1c...e2 users:read users:read_pii users:write; ops 9a0b...41 users:read
+func ParseAPIKeys(spec string) (*APIKeys, error) {
+	keys := &APIKeys{}
+	for i, entry := range strings.Split(spec, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("api key %d: expected a name, a hash and at least one scope", i+1)
+		}
+
+		hash, err := hex.DecodeString(fields[1])
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("api key %s: hash must be a hex encoded SHA-256", fields[0])
+		}
+
+		keys.keys = append(keys.keys, apiKey{
+			name:   fields[0],
+			hash:   hash,
+			scopes: fields[2:],
+		})
+	}
+	return keys, nil
+}
+
+// Authenticate returns the principal of key, or false when
+// the key is unknown
+func (a *APIKeys) Authenticate(key string) (*Principal, bool) {
+	sum := sha256.Sum256([]byte(key))
+
+	var found *apiKey
+	for i := range a.keys {
+		// every key is compared so the time does not depend on the match
+		if subtle.ConstantTimeCompare(sum[:], a.keys[i].hash) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+
+	return &Principal{
+		Subject: found.name,
+		Method:  "api_key",
+		Scopes:  found.scopes,
+	}, true
+}
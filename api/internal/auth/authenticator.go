@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// Authenticator resolves the principal of a request from its
+// X-API-Key header or its "Authorization: Bearer" JWT
+type Authenticator struct {
+	keys *APIKeys
+	jwt  *JWTVerifier
+}
+
+// NewAuthenticator creates an authenticator, jwt is nil when
+// bearer tokens are not accepted
+func NewAuthenticator(keys *APIKeys, jwt *JWTVerifier) *Authenticator {
+	return &Authenticator{
+		keys: keys,
+		jwt:  jwt,
+	}
+}
+
+// Authenticate returns the principal of r, nil without error
+// when the request carries no credentials
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.AuthenticateCredentials(r.Header.Get(apiKeyHeader), r.Header.Get("Authorization"))
+}
+
+// AuthenticateCredentials is Authenticate for transports other than
+// HTTP, taking the values of the api key and authorization headers
+func (a *Authenticator) AuthenticateCredentials(key, header string) (*Principal, error) {
+	if key != "" {
+		p, ok := a.keys.Authenticate(key)
+		if !ok {
+			return nil, errors.New("invalid api key")
+		}
+		return p, nil
+	}
+
+	if header == "" {
+		return nil, nil
+	}
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, errors.New("unsupported authorization scheme")
+	}
+	if a.jwt == nil {
+		return nil, errors.New("bearer tokens are not accepted")
+	}
+
+	p, err := a.jwt.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return nil, errors.New("invalid bearer token")
+	}
+	return p, nil
+}
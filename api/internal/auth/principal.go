@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// scopes granted to api keys and tokens
+const (
+	ScopeUsersRead    = "users:read"
+	ScopeUsersReadPII = "users:read_pii"
+	ScopeUsersWrite   = "users:write"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	// Subject is the api key name or the token subject
+	Subject string
+	// Method is either "api_key" or "jwt"
+	Method string
+	Scopes []string
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal carried by ctx, or nil
+// for anonymous requests
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
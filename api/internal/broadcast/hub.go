@@ -0,0 +1,208 @@
+package broadcast
+
+import (
+	"api/internal/entity"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// historySize is how many of the last changes are kept for the
+// subscribers resuming after a disconnection
+const historySize = 1024
+
+// ErrSlowSubscriber is reported to the subscribers dropped for not
+// keeping up with the changes
+var ErrSlowSubscriber = errors.New("subscriber too slow, changes were dropped")
+
+// Hub fans out the user changes to its subscribers. The changes are
+// the events of the outbox, received in the order of their ids, which
+// are the change ids, so every api instance gives a change the same
+// id. Publishing never blocks: a subscriber whose buffer is full is
+// dropped, and may resume from the last change it got while the
+// changes after it are still in the history
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+
+	// history is a ring holding the last changes received, the
+	// oldest at start once it is full
+	history []change
+	start   int
+	// every change with an id above from is in the history, it is
+	// only known once a change was received since the last reset
+	from  int64
+	known bool
+}
+
+// change is a change of the history with its parsed id
+type change struct {
+	id     int64
+	change entity.UserChange
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs:    map[*Subscription]struct{}{},
+		history: make([]change, 0, historySize),
+	}
+}
+
+// Subscription receives the changes published after it was created
+type Subscription struct {
+	hub *Hub
+	ch  chan entity.UserChange
+	err error
+	// after skips the changes up to this id, already sent when
+	// resuming on an instance behind the previous one
+	after int64
+}
+
+// Subscribe creates a subscription buffering up to buffer changes
+func (h *Hub) Subscribe(buffer int) *Subscription {
+	s, _ := h.SubscribeAfter(buffer, "")
+	return s
+}
+
+// SubscribeAfter creates a subscription buffering up to buffer
+// changes, starting with the changes of the history that came after
+// lastID. Returns false when lastID is not empty and the changes
+// after it are not all kept, the subscription then starts from now
+func (h *Hub) SubscribeAfter(buffer int, lastID string) (*Subscription, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	after, missed, ok := h.after(lastID)
+	s := &Subscription{
+		hub:   h,
+		ch:    make(chan entity.UserChange, buffer+len(missed)),
+		after: after,
+	}
+	for _, change := range missed {
+		s.ch <- change
+	}
+
+	h.subs[s] = struct{}{}
+	return s, ok
+}
+
+// after returns the id lastID stands for and the changes of the
+// history after it, must be called with the lock held
+func (h *Hub) after(lastID string) (int64, []entity.UserChange, bool) {
+	if lastID == "" {
+		return 0, nil, true
+	}
+
+	last, err := strconv.ParseInt(lastID, 10, 64)
+	if err != nil || !h.known || last < h.from {
+		return 0, nil, false
+	}
+
+	var missed []entity.UserChange
+	for i := range h.history {
+		c := h.history[(h.start+i)%len(h.history)]
+		if c.id > last {
+			missed = append(missed, c.change)
+		}
+	}
+	return last, missed, true
+}
+
+// Notify publishes the change to every subscriber. Its id must be
+// the id of its outbox event, a change without one is published
+// but breaks the history, as a reset does
+func (h *Hub) Notify(c entity.UserChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	id, err := strconv.ParseInt(c.ID, 10, 64)
+	if err != nil {
+		h.clear()
+	} else {
+		h.keep(change{id: id, change: c})
+	}
+
+	for s := range h.subs {
+		if err == nil && id <= s.after {
+			continue
+		}
+		h.send(s, c)
+	}
+}
+
+// keep adds the change to the history, must be called with the
+// lock held
+func (h *Hub) keep(c change) {
+	if !h.known {
+		h.from, h.known = c.id-1, true
+	}
+	if len(h.history) < historySize {
+		h.history = append(h.history, c)
+		return
+	}
+	h.from = h.history[h.start].id
+	h.history[h.start] = c
+	h.start = (h.start + 1) % historySize
+}
+
+// Reset tells the hub the changes published since the last one it
+// got may not have been received. The history is cleared, and the
+// subscribers are sent ChangesLost
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clear()
+	lost := entity.UserChange{Type: entity.ChangesLost, At: time.Now().UTC()}
+	for s := range h.subs {
+		h.send(s, lost)
+	}
+}
+
+// clear must be called with the lock held
+func (h *Hub) clear() {
+	h.history = h.history[:0]
+	h.start = 0
+	h.known = false
+}
+
+// send drops the subscriber if its buffer is full, must be called
+// with the lock held
+func (h *Hub) send(s *Subscription, c entity.UserChange) {
+	select {
+	case s.ch <- c:
+	default:
+		s.err = ErrSlowSubscriber
+		h.remove(s)
+	}
+}
+
+// remove must be called with the lock held
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// C delivers the changes, it is closed once the subscription is
+// closed or dropped
+func (s *Subscription) C() <-chan entity.UserChange {
+	return s.ch
+}
+
+// Err returns ErrSlowSubscriber once C is closed because the
+// subscriber was dropped
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
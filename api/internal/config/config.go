@@ -0,0 +1,89 @@
+package config
+
+import (
+	loader "desafio/pkg/config"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Config holds every setting of the api, see desafio/pkg/config
+// for the meaning of the tags
+type Config struct {
+	APIPort  string `config:"API_PORT" default:":8080" validate:"required,hostport" usage:"address the HTTP api listens on"`
+	GRPCPort string `config:"GRPC_PORT" default:":9090" validate:"required,hostport" usage:"address the gRPC api listens on"`
+	LogLevel string `config:"LOG_LEVEL" default:"info" validate:"loglevel" usage:"one of debug, info, warn or error"`
+
+	CryptorKey string `config:"CRYPTOR_KEY" secret:"true" validate:"required,hexkey32" usage:"32 bytes hex key used to encrypt emails"`
+
+	DBHost   string `config:"DB_HOST" validate:"required" usage:"database host"`
+	DBPort   int    `config:"DB_PORT" default:"5432" validate:"port" usage:"database port"`
+	DBUser   string `config:"DB_USER" validate:"required" usage:"database user"`
+	DBPass   string `config:"DB_PASS" secret:"true" usage:"database password"`
+	DBName   string `config:"DB_NAME" validate:"required" usage:"database name"`
+	DBDriver string `config:"DB_DRIVER" default:"postgres" validate:"required" usage:"database/sql driver name"`
+	// DBMaxConns bounds the pool shared by the routes and the consumer
+	DBMaxConns int `config:"DB_MAX_CONNS" default:"20" validate:"positive" usage:"maximum open database connections"`
+
+	CacheURL  string `config:"CACHE_URL" validate:"required,hostport" usage:"redis address"`
+	CachePass string `config:"CACHE_PASS" secret:"true" usage:"redis password"`
+
+	AMQPURL   string `config:"AMQP_URL" validate:"required,amqpurl" usage:"RabbitMQ URL"`
+	AMQPQueue string `config:"AMQP_QUEUE" validate:"required" usage:"queue the users are consumed from"`
+	// ConsumerWorkers upsert the consumed users, 0 uses half of the
+	// database connections, leaving the rest to the routes
+	ConsumerWorkers int           `config:"CONSUMER_WORKERS" usage:"workers upserting the consumed users, 0 for half of DB_MAX_CONNS"`
+	DedupTTL        time.Duration `config:"DEDUP_TTL" default:"24h" validate:"positive" usage:"how long the ids of the consumed messages are remembered to skip redeliveries"`
+	// EventsExchange receives the user.created, user.updated,
+	// user.merged and user.deleted events
+	EventsExchange string `config:"EVENTS_EXCHANGE" default:"users.events" validate:"required" usage:"topic exchange the user change events are published to"`
+
+	APIKeys     string `config:"API_KEYS" secret:"true" usage:"; separated api keys, each one a name, the hex SHA-256 of the key and its scopes"`
+	JWKSFile    string `config:"JWKS_FILE" usage:"JWKS file with the keys bearer tokens are signed with, tokens are rejected when empty"`
+	JWTIssuer   string `config:"JWT_ISSUER" usage:"expected iss claim of bearer tokens"`
+	JWTAudience string `config:"JWT_AUDIENCE" usage:"expected aud claim of bearer tokens"`
+
+	GraphQLMaxDepth      int `config:"GRAPHQL_MAX_DEPTH" default:"8" validate:"positive" usage:"deepest field nesting accepted by /api/graphql"`
+	GraphQLMaxComplexity int `config:"GRAPHQL_MAX_COMPLEXITY" default:"1000" validate:"positive" usage:"highest cost accepted by /api/graphql, a field costs 1 and the selection of a list 10 times its cost"`
+
+	RateLimits  string `config:"RATE_LIMITS" usage:"; separated token buckets per client and route, \"default <rate> <burst>\" or \"<METHOD> <route> <rate> <burst>\" with rate as <n>/s, <n>/m or <n>/h, empty to disable"`
+	IPRateLimit string `config:"IP_RATE_LIMIT" usage:"token bucket per IP taken before authenticating, across every route and the gRPC methods, \"<rate> <burst>\" as in RATE_LIMITS, empty to disable"`
+
+	OTLPEndpoint string `config:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"httpurl" usage:"OTLP/HTTP collector endpoint, tracing export is disabled when empty"`
+
+	printConfig bool
+}
+
+// Load reads the config from the defaults, the file given by
+// -config or CONFIG_FILE, the environment (and an optional .env)
+// and the command line flags in args, the last one wins. All the
+// invalid values are reported at once
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	printConfig, err := loader.Load(cfg, args)
+	cfg.printConfig = printConfig
+	return cfg, err
+}
+
+// PrintRequested reports whether -print-config was given
+func (c *Config) PrintRequested() bool {
+	return c.printConfig
+}
+
+// Print writes the effective config with the secrets redacted
+func (c *Config) Print(w io.Writer) {
+	loader.Write(c, w)
+}
+
+// Workers returns the number of consumer workers
+func (c *Config) Workers() int {
+	if c.ConsumerWorkers > 0 {
+		return c.ConsumerWorkers
+	}
+	return max(c.DBMaxConns/2, 1)
+}
+
+// Datasource returns the connection string of the database
+func (c *Config) Datasource() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", c.DBHost, c.DBPort, c.DBUser, c.DBPass, c.DBName)
+}
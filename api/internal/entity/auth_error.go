@@ -0,0 +1,31 @@
+package entity
+
+import "fmt"
+
+// UnauthorizedError is returned when the request carries no
+// or invalid credentials
+type UnauthorizedError struct {
+	err error
+}
+
+func NewUnauthorizedError(format string, a ...any) *UnauthorizedError {
+	return &UnauthorizedError{err: fmt.Errorf(format, a...)}
+}
+
+func (e *UnauthorizedError) Error() string {
+	return e.err.Error()
+}
+
+// ForbiddenError is returned when the caller lacks the scope
+// required by the operation
+type ForbiddenError struct {
+	err error
+}
+
+func NewForbiddenError(format string, a ...any) *ForbiddenError {
+	return &ForbiddenError{err: fmt.Errorf(format, a...)}
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+type ImportStatus string
+
+const (
+	ImportStatusRunning   ImportStatus = "running"
+	ImportStatusCompleted ImportStatus = "completed"
+	ImportStatusFailed    ImportStatus = "failed"
+)
+
+// Import tracks the progress of a CSV file being sent to the queue
+type Import struct {
+	ID         string        `json:"id"`
+	Filename   string        `json:"filename"`
+	Status     ImportStatus  `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Published  int           `json:"published"`
+	Rejected   int           `json:"rejected"`
+	Rejections []RejectedRow `json:"rejections"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at"`
+}
+
+// RejectedRow is a CSV row that could not be parsed. Only the
+// id column is kept so no personal data is stored, the malformed
+// rows have none
+type RejectedRow struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,17 @@
+package entity
+
+import "fmt"
+
+// PreconditionError is returned when a conditional write
+// does not match the current state of the resource
+type PreconditionError struct {
+	err error
+}
+
+func NewPreconditionError(format string, a ...any) *PreconditionError {
+	return &PreconditionError{err: fmt.Errorf(format, a...)}
+}
+
+func (e *PreconditionError) Error() string {
+	return e.err.Error()
+}
@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimitError is returned when the caller exhausted its
+// request budget
+type RateLimitError struct {
+	err error
+	// RetryAfter is the wait until the next request is allowed
+	RetryAfter time.Duration
+}
+
+func NewRateLimitError(retryAfter time.Duration, format string, a ...any) *RateLimitError {
+	return &RateLimitError{err: fmt.Errorf(format, a...), RetryAfter: retryAfter}
+}
+
+func (e *RateLimitError) Error() string {
+	return e.err.Error()
+}
@@ -11,4 +11,5 @@ type User struct {
 	DeletedAt    *time.Time `json:"deleted_at"`
 	MergedAt     *time.Time `json:"merged_at"`
 	ParentUserID *int64     `json:"parent_user_id"`
+	Version      int64      `json:"version"`
 }
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+type UserChangeType string
+
+const (
+	UserCreated UserChangeType = "user.created"
+	UserUpdated UserChangeType = "user.updated"
+	UserDeleted UserChangeType = "user.deleted"
+	UserMerged  UserChangeType = "user.merged"
+
+	// ChangesLost is sent to a watcher resuming after a change that
+	// is no longer kept, or when changes may have been missed, it
+	// should read the users it follows again
+	ChangesLost UserChangeType = "changes.lost"
+)
+
+// UserChange is a change applied to a user, holding the user as
+// stored, with its email encrypted
+type UserChange struct {
+	// ID is the id of the outbox event of the change, the same on
+	// every api instance, watchers resume after it
+	ID   string
+	Type UserChangeType
+	User User
+	At   time.Time
+}
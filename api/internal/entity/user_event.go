@@ -0,0 +1,13 @@
+package entity
+
+// UserEvent is a change recorded in the outbox by the write that
+// caused it, waiting to be published
+type UserEvent struct {
+	ID     int64
+	Type   UserChangeType
+	UserID int64
+	// Payload is the JSON body of the event
+	Payload []byte
+	// Headers holds the trace context of the write
+	Headers map[string]string
+}
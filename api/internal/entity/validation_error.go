@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes why a single input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError groups every field error found while
+// validating a request
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add registers a new field error
+func (e *ValidationError) Add(field, format string, a ...any) {
+	e.Fields = append(e.Fields, FieldError{
+		Field:   field,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// HasErrors reports whether any field error was registered
+func (e *ValidationError) HasErrors() bool {
+	return len(e.Fields) > 0
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return "invalid input: " + strings.Join(msgs, "; ")
+}
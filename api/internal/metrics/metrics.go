@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "challenge"
+
+var (
+	// HTTPRequestDuration observes the latency of every request by route
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// CacheRequests counts cache lookups by result: hit, miss or error
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by result.",
+	}, []string{"result"})
+
+	// ConsumerMessages counts the consumed messages by result: processed,
+	// invalid, duplicate, requeued or failed
+	ConsumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_total",
+		Help:      "Messages consumed from the queue by result.",
+	}, []string{"result"})
+
+	// ConsumerUsers counts the consumed users by result: upserted or failed
+	ConsumerUsers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "users_total",
+		Help:      "Users consumed from the queue by result.",
+	}, []string{"result"})
+
+	// OutboxEvents counts the events of the outbox by result: published or failed
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_total",
+		Help:      "Events of the outbox by publishing result.",
+	}, []string{"result"})
+)
+
+// RegisterDB exposes the connection pool statistics of db
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterQueueDepth exposes the number of messages waiting in the
+// queue, read through messages on every scrape
+func RegisterQueueDepth(messages func() (int, error)) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "queue_messages",
+		Help:      "Messages ready in the queue and not consumed yet, -1 if unknown.",
+	}, func() float64 {
+		n, err := messages()
+		if err != nil {
+			return -1
+		}
+		return float64(n)
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"api/internal/entity"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"io"
 )
 
 type userCryptor struct {
@@ -25,6 +27,27 @@ func NewUserCryptor(key string) (*userCryptor, error) {
 	return &userCryptor{key: keyBytes}, nil
 }
 
+// Encrypt encrypts the email of the provided user
+func (e *userCryptor) Encrypt(user *entity.User) error {
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return err
+	}
+
+	ciphertext := make([]byte, aes.BlockSize+len(user.Email))
+	iv := ciphertext[:aes.BlockSize]
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return err
+	}
+
+	stream := cipher.NewCFBEncrypter(block, iv)
+	stream.XORKeyStream(ciphertext[aes.BlockSize:], []byte(user.Email))
+
+	user.Email = hex.EncodeToString(ciphertext)
+
+	return nil
+}
+
 // Decrypt decrypts the provided encrypted user
 func (e *userCryptor) Decrypt(user *entity.User) error {
 	ciphertext, err := hex.DecodeString(user.Email)
@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"fmt"
+)
+
+type changeSubscriber interface {
+	Subscribe(ch chan<- entity.UserChange) error
+}
+
+// changeNotifier is told about every change applied to the users
+type changeNotifier interface {
+	Notify(change entity.UserChange)
+	Reset()
+}
+
+// broadcastUsecase hands the user events published by every api
+// instance to the watchers of this one
+type broadcastUsecase struct {
+	subscriber changeSubscriber
+	notifier   changeNotifier
+}
+
+func NewBroadcastUsecase(subscriber changeSubscriber, notifier changeNotifier) *broadcastUsecase {
+	return &broadcastUsecase{
+		subscriber: subscriber,
+		notifier:   notifier,
+	}
+}
+
+// Execute notifies the events until the subscription ends. The
+// events published while not subscribed are lost, so the notifier
+// is reset every time it subscribes
+func (u *broadcastUsecase) Execute(ctx context.Context) error {
+	changes := make(chan entity.UserChange)
+	if err := u.subscriber.Subscribe(changes); err != nil {
+		return fmt.Errorf("failed to subscribe to the user events: %v", err)
+	}
+	u.notifier.Reset()
+
+	for change := range changes {
+		u.notifier.Notify(change)
+	}
+	return nil
+}
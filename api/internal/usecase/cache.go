@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+type userCache interface {
+	Set(ctx context.Context, key string, data string, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// searchVersionKey holds the version of the cached searches, part
+// of their keys, so bumping it leaves the searches cached before
+// unread until they expire
+const searchVersionKey = "search:version"
+
+type searchVersioner interface {
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// invalidateSearches bumps the version of the cached searches, the
+// writes call it once committed so the next searches observe them
+func invalidateSearches(ctx context.Context, cache searchVersioner) error {
+	_, err := cache.Incr(ctx, searchVersionKey)
+	return err
+}
+
+// setUserCache stores the (still encrypted) user under the same
+// key used by getByIDUsecase, so reads observe the latest write
+func setUserCache(ctx context.Context, cache userCache, user entity.User, ttl time.Duration) error {
+	toCache, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return cache.Set(ctx, strconv.FormatInt(user.ID, 10), string(toCache), ttl)
+}
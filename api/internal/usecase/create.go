@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type createCryptor interface {
+	Encrypt(user *entity.User) error
+	Decrypt(user *entity.User) error
+}
+
+type createRepo interface {
+	Create(ctx context.Context, user entity.User) (*entity.User, error)
+}
+
+const (
+	createCacheExp = time.Minute * 15
+)
+
+type createUsecase struct {
+	repo    createRepo
+	cache   userCache
+	cryptor createCryptor
+}
+
+func NewCreateUsecase(repo createRepo, cache userCache, cryptor createCryptor) *createUsecase {
+	return &createUsecase{
+		repo:    repo,
+		cache:   cache,
+		cryptor: cryptor,
+	}
+}
+
+// Execute stores a new user. Returns a business error if the id
+// or the email is taken, and a validation error if the parent does
+// not exist
+func (u *createUsecase) Execute(ctx context.Context, user entity.User) (*entity.User, error) {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+	}
+
+	if err := u.cryptor.Encrypt(&user); err != nil {
+		return nil, err
+	}
+
+	created, err := u.repo.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	user = *created
+
+	if err := setUserCache(ctx, u.cache, user, createCacheExp); err != nil {
+		slog.ErrorContext(ctx, "create-usecase", slog.Group("Execute", "set user to cache", err))
+	}
+	if err := invalidateSearches(ctx, u.cache); err != nil {
+		slog.ErrorContext(ctx, "create-usecase", slog.Group("Execute", "invalidate searches", err))
+	}
+
+	pii := newPIIReader(ctx, u.cryptor)
+	if err := pii.Reveal(&user); err != nil {
+		return nil, err
+	}
+	pii.Audit(ctx, "users.create")
+
+	return &user, nil
+}
@@ -0,0 +1,65 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type deleteRepo interface {
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	Update(ctx context.Context, user entity.User, version *int64) (*entity.User, error)
+}
+
+const (
+	deleteCacheExp = time.Minute * 15
+)
+
+type deleteUsecase struct {
+	repo  deleteRepo
+	cache userCache
+}
+
+func NewDeleteUsecase(repo deleteRepo, cache userCache) *deleteUsecase {
+	return &deleteUsecase{
+		repo:  repo,
+		cache: cache,
+	}
+}
+
+// Execute soft deletes the user by setting its deleted_at. When
+// version is given it must match the stored version. Deleting an
+// already deleted user is a no-op. Returns nil if the user does
+// not exist. The returned user keeps its email encrypted
+func (u *deleteUsecase) Execute(ctx context.Context, id string, version *int64) (*entity.User, error) {
+	user, err := u.repo.GetByID(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	if version != nil && *version != user.Version {
+		return nil, entity.NewPreconditionError("user %d was modified, current version is %d", user.ID, user.Version)
+	}
+
+	if user.DeletedAt != nil {
+		return user, nil
+	}
+
+	now := time.Now().UTC()
+	user.DeletedAt = &now
+
+	deleted, err := u.repo.Update(ctx, *user, &user.Version)
+	if err != nil || deleted == nil {
+		return nil, err
+	}
+
+	if err := setUserCache(ctx, u.cache, *deleted, deleteCacheExp); err != nil {
+		slog.ErrorContext(ctx, "delete-usecase", slog.Group("Execute", "set user to cache", err))
+	}
+	if err := invalidateSearches(ctx, u.cache); err != nil {
+		slog.ErrorContext(ctx, "delete-usecase", slog.Group("Execute", "invalidate searches", err))
+	}
+
+	return deleted, nil
+}
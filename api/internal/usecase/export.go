@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"api/internal/adapter"
+	"api/internal/entity"
+	"context"
+)
+
+const exportAuditPage = 500
+
+type exportCryptor interface {
+	Decrypt(user *entity.User) error
+}
+
+type exportRepo interface {
+	Export(ctx context.Context, queryOpts adapter.QueryOpts, fn func(user entity.User) error) error
+}
+
+type exportUsecase struct {
+	repo    exportRepo
+	cryptor exportCryptor
+}
+
+func NewExportUsecase(repo exportRepo, cryptor exportCryptor) *exportUsecase {
+	return &exportUsecase{
+		repo:    repo,
+		cryptor: cryptor,
+	}
+}
+
+// Execute streams every user matching input to fn, one at time and
+// already decrypted (or masked). Exports bypass the cache since they
+// are not expected to be repeated
+func (u *exportUsecase) Execute(ctx context.Context, input SearchInput, fn func(user entity.User) error) error {
+	if err := checkEmailFilter(ctx, input.Email); err != nil {
+		return err
+	}
+
+	opts := adapter.QueryOpts{
+		FirstName: input.FirstName,
+		LastName:  input.LastName,
+		Email:     input.Email,
+		Fields:    input.Fields,
+	}
+
+	// unmasked rows are audited in pages, exports can be large
+	pii := newPIIReader(ctx, u.cryptor).forFields(input.Fields)
+	defer pii.Audit(ctx, "users.export")
+
+	return u.repo.Export(ctx, opts, func(user entity.User) error {
+		if err := pii.Reveal(&user); err != nil {
+			return err
+		}
+		if len(pii.ids) == exportAuditPage {
+			pii.Audit(ctx, "users.export")
+		}
+		return fn(user)
+	})
+}
@@ -43,6 +43,8 @@ func NewGetByIDUsecase(repo getByIDRepo, cache getByIdCache, cryptor getByIDCryp
 	}
 }
 
+// Execute returns the user with the given id, or nil if it
+// does not exist
 func (u *getByIDUsecase) Execute(ctx context.Context, id string) (*entity.User, error) {
 	if strings.TrimSpace(id) == "" {
 		return nil, entity.NewBusinessError("invalid empty id")
@@ -59,14 +61,14 @@ func (u *getByIDUsecase) Execute(ctx context.Context, id string) (*entity.User,
 		if err := json.Unmarshal([]byte(*cached), &user); err != nil {
 			return nil, err
 		}
-		if err := u.cryptor.Decrypt(&user); err != nil {
+		if err := u.reveal(ctx, &user); err != nil {
 			return nil, err
 		}
 		return &user, nil
 	}
 
 	userData, err := u.repo.GetByID(ctx, id)
-	if err != nil {
+	if err != nil || userData == nil {
 		return nil, err
 	}
 
@@ -76,16 +78,16 @@ func (u *getByIDUsecase) Execute(ctx context.Context, id string) (*entity.User,
 		defer wg.Done()
 		toCache, err := json.Marshal(user)
 		if err != nil {
-			slog.Error("getByID-usecase", slog.Group("Execute", "marshal to cache", err))
+			slog.ErrorContext(ctx, "getByID-usecase", slog.Group("Execute", "marshal to cache", err))
 			return
 		}
 		if err = u.cache.Set(ctx, id, string(toCache), getByIDCacheExp); err != nil {
-			slog.Error("getByID-usecase", slog.Group("Execute", "set user to cache", err))
+			slog.ErrorContext(ctx, "getByID-usecase", slog.Group("Execute", "set user to cache", err))
 			return
 		}
 	}(*userData)
 
-	if err := u.cryptor.Decrypt(userData); err != nil {
+	if err := u.reveal(ctx, userData); err != nil {
 		return nil, err
 	}
 
@@ -93,3 +95,12 @@ func (u *getByIDUsecase) Execute(ctx context.Context, id string) (*entity.User,
 
 	return userData, nil
 }
+
+func (u *getByIDUsecase) reveal(ctx context.Context, user *entity.User) error {
+	pii := newPIIReader(ctx, u.cryptor)
+	if err := pii.Reveal(user); err != nil {
+		return err
+	}
+	pii.Audit(ctx, "users.get")
+	return nil
+}
@@ -0,0 +1,37 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"encoding/json"
+)
+
+type getImportStore interface {
+	Get(ctx context.Context, key string) (*string, error)
+}
+
+type getImportUsecase struct {
+	store getImportStore
+}
+
+func NewGetImportUsecase(store getImportStore) *getImportUsecase {
+	return &getImportUsecase{
+		store: store,
+	}
+}
+
+// Execute returns the import with the given id, or nil
+// if it does not exist or has already expired
+func (u *getImportUsecase) Execute(ctx context.Context, id string) (*entity.Import, error) {
+	data, err := u.store.Get(ctx, importKey(id))
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	var imp entity.Import
+	if err := json.Unmarshal([]byte(*data), &imp); err != nil {
+		return nil, err
+	}
+
+	return &imp, nil
+}
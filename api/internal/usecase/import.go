@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"crypto/rand"
+	"desafio/pkg/producer"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type importCryptor interface {
+	Encrypt(val string) (string, error)
+}
+
+type importStore interface {
+	Set(ctx context.Context, key string, data string, ttl time.Duration) error
+}
+
+const (
+	importExp = time.Hour * 24
+
+	// caps the rejected rows kept for a single import, the
+	// rejected counter keeps counting past it
+	maxImportRejections = 1000
+)
+
+type importUsecase struct {
+	queue   producer.AmqpAdapter
+	cryptor importCryptor
+	store   importStore
+}
+
+func NewImportUsecase(queue producer.AmqpAdapter, cryptor importCryptor, store importStore) *importUsecase {
+	return &importUsecase{
+		queue:   queue,
+		cryptor: cryptor,
+		store:   store,
+	}
+}
+
+// Execute sends the uploaded CSV to the queue as it is read, using
+// the same pipeline as the producer. It returns once the upload is
+// read, the last batches are published in background. The returned
+// import can be followed through getImportUsecase
+func (u *importUsecase) Execute(ctx context.Context, filename string, upload io.Reader, batchSize int) (*entity.Import, error) {
+	id, err := newImportID()
+	if err != nil {
+		return nil, err
+	}
+
+	imp := entity.Import{
+		ID:         id,
+		Filename:   filename,
+		Status:     entity.ImportStatusRunning,
+		Rejections: []entity.RejectedRow{},
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := u.save(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	body := &uploadReader{Reader: upload, read: make(chan struct{})}
+	done := make(chan struct{})
+	// keeps the trace of the request but not its cancellation
+	go func() {
+		defer close(done)
+		u.run(context.WithoutCancel(ctx), imp, body, batchSize)
+	}()
+
+	// the upload belongs to the request, it cannot be read once
+	// Execute returns
+	select {
+	case <-body.read:
+	case <-done:
+	}
+
+	return &imp, nil
+}
+
+// run produces the upload, it outlives the request so ctx must
+// not be canceled when the request ends
+func (u *importUsecase) run(ctx context.Context, imp entity.Import, upload io.Reader, batchSize int) {
+	tracker := &importTracker{ctx: ctx, imp: imp, save: u.save}
+	err := u.produce(ctx, imp.Filename, upload, batchSize, tracker)
+
+	now := time.Now().UTC()
+	tracker.imp.FinishedAt = &now
+	tracker.imp.Status = entity.ImportStatusCompleted
+	if err != nil {
+		tracker.imp.Status = entity.ImportStatusFailed
+		tracker.imp.Error = err.Error()
+		slog.ErrorContext(ctx, "import-usecase", slog.Group("run", "id", imp.ID, "produce", err))
+	}
+
+	if err := u.save(ctx, tracker.imp); err != nil {
+		slog.ErrorContext(ctx, "import-usecase", slog.Group("run", "id", imp.ID, "save import", err))
+	}
+}
+
+func (u *importUsecase) produce(ctx context.Context, filename string, upload io.Reader, batchSize int, observer producer.Observer) error {
+	reader, err := producer.NewStreamReader(filename, upload, producer.ReaderOptions{Format: producer.FormatCSV})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	parser := producer.NewCsvUserParser(u.cryptor)
+
+	return producer.NewUserProducer(reader, parser, u.queue).
+		WithObserver(observer).
+		Produce(ctx, batchSize)
+}
+
+// uploadReader closes read once the upload is read to the end
+// or fails
+type uploadReader struct {
+	io.Reader
+	once sync.Once
+	read chan struct{}
+}
+
+func (r *uploadReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		r.once.Do(func() { close(r.read) })
+	}
+	return n, err
+}
+
+func (u *importUsecase) save(ctx context.Context, imp entity.Import) error {
+	data, err := json.Marshal(imp)
+	if err != nil {
+		return err
+	}
+	return u.store.Set(ctx, importKey(imp.ID), string(data), importExp)
+}
+
+// importTracker records the progress reported by the producer
+// and saves it after every published batch
+type importTracker struct {
+	ctx  context.Context
+	imp  entity.Import
+	save func(ctx context.Context, imp entity.Import) error
+}
+
+func (t *importTracker) Rejected(r producer.Record, err error) {
+	t.imp.Rejected++
+	if len(t.imp.Rejections) >= maxImportRejections {
+		return
+	}
+
+	var id string
+	if len(r) > 0 {
+		id = r[0]
+	}
+	t.imp.Rejections = append(t.imp.Rejections, entity.RejectedRow{ID: id, Error: err.Error()})
+}
+
+func (t *importTracker) Published(users int) {
+	t.imp.Published += users
+	if err := t.save(t.ctx, t.imp); err != nil {
+		slog.ErrorContext(t.ctx, "import-usecase", slog.Group("Published", "id", t.imp.ID, "save import", err))
+	}
+}
+
+func importKey(id string) string {
+	return "import:" + id
+}
+
+func newImportID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"log/slog"
+	"strconv"
+)
+
+type mergeCryptor interface {
+	Decrypt(user *entity.User) error
+}
+
+type mergeRepo interface {
+	Merge(ctx context.Context, id, targetID int64) (*entity.User, []int64, error)
+}
+
+type mergeCache interface {
+	Del(ctx context.Context, keys ...string) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+type mergeUsecase struct {
+	repo    mergeRepo
+	cache   mergeCache
+	cryptor mergeCryptor
+}
+
+func NewMergeUsecase(repo mergeRepo, cache mergeCache, cryptor mergeCryptor) *mergeUsecase {
+	return &mergeUsecase{
+		repo:    repo,
+		cache:   cache,
+		cryptor: cryptor,
+	}
+}
+
+// Execute merges the user into target and drops the cached entries
+// of every user changed by the merge. Returns nil if the user does
+// not exist
+func (u *mergeUsecase) Execute(ctx context.Context, id, targetID int64) (*entity.User, error) {
+	if id == targetID {
+		return nil, entity.NewBusinessError("user %d cannot be merged into itself", id)
+	}
+
+	user, children, err := u.repo.Merge(ctx, id, targetID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(children)+1)
+	keys = append(keys, strconv.FormatInt(id, 10))
+	for _, child := range children {
+		keys = append(keys, strconv.FormatInt(child, 10))
+	}
+	if err := u.cache.Del(ctx, keys...); err != nil {
+		slog.ErrorContext(ctx, "merge-usecase", slog.Group("Execute", "delete users from cache", err))
+	}
+	if err := invalidateSearches(ctx, u.cache); err != nil {
+		slog.ErrorContext(ctx, "merge-usecase", slog.Group("Execute", "invalidate searches", err))
+	}
+
+	pii := newPIIReader(ctx, u.cryptor)
+	if err := pii.Reveal(user); err != nil {
+		return nil, err
+	}
+	pii.Audit(ctx, "users.merge")
+
+	return user, nil
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"api/internal/metrics"
+	"context"
+	"fmt"
+	"time"
+)
+
+type outboxRepo interface {
+	PublishEvents(ctx context.Context, limit int, fn func(event entity.UserEvent) error) (int, error)
+}
+
+type eventPublisher interface {
+	Publish(ctx context.Context, event entity.UserEvent) error
+}
+
+const (
+	outboxBatchSize    = 100
+	outboxPollInterval = time.Second
+)
+
+// outboxRelayUsecase publishes the events recorded in the outbox
+// by the user writes. An event is removed once the broker confirmed
+// it, so a crash in between publishes it again but never loses it
+type outboxRelayUsecase struct {
+	repo      outboxRepo
+	publisher eventPublisher
+}
+
+func NewOutboxRelayUsecase(repo outboxRepo, publisher eventPublisher) *outboxRelayUsecase {
+	return &outboxRelayUsecase{
+		repo:      repo,
+		publisher: publisher,
+	}
+}
+
+// Execute publishes the events until ctx is done or publishing
+// fails, polling the outbox when it is empty
+func (u *outboxRelayUsecase) Execute(ctx context.Context) error {
+	for {
+		n, err := u.repo.PublishEvents(ctx, outboxBatchSize, func(event entity.UserEvent) error {
+			return u.publisher.Publish(ctx, event)
+		})
+		metrics.OutboxEvents.WithLabelValues("published").Add(float64(n))
+		if err != nil {
+			metrics.OutboxEvents.WithLabelValues("failed").Inc()
+			return fmt.Errorf("failed to publish the outbox events: %w", err)
+		}
+
+		// a full batch means there may be more waiting
+		if n == outboxBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(outboxPollInterval):
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"api/internal/audit"
+	"api/internal/auth"
+	"api/internal/entity"
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+type piiCryptor interface {
+	Decrypt(user *entity.User) error
+}
+
+// piiReader decrypts the emails returned to the caller of a
+// request, masking them unless the caller was granted the
+// users:read_pii scope, and keeps the ids of the unmasked users
+// for the audit log
+type piiReader struct {
+	cryptor piiCryptor
+	allowed bool
+	// skip is set when the email was not requested, it is
+	// neither decrypted nor audited
+	skip bool
+	ids  []int64
+}
+
+func newPIIReader(ctx context.Context, cryptor piiCryptor) *piiReader {
+	return &piiReader{
+		cryptor: cryptor,
+		allowed: auth.PrincipalFrom(ctx).HasScope(auth.ScopeUsersReadPII),
+	}
+}
+
+// forFields makes the reader skip the emails unless fields, comma
+// separated, has email_address
+func (r *piiReader) forFields(fields string) *piiReader {
+	r.skip = true
+	for _, f := range strings.Split(fields, ",") {
+		if strings.TrimSpace(f) == "email_address" {
+			r.skip = false
+		}
+	}
+	return r
+}
+
+// Reveal decrypts the email of user, masking it when the caller
+// may not read it. An email that was not requested is dropped
+func (r *piiReader) Reveal(user *entity.User) error {
+	if r.skip {
+		user.Email = ""
+		return nil
+	}
+
+	// the email was not selected
+	if user.Email == "" {
+		return nil
+	}
+
+	if err := r.cryptor.Decrypt(user); err != nil {
+		return err
+	}
+
+	if !r.allowed {
+		user.Email = maskEmail(user.Email)
+		return nil
+	}
+
+	r.ids = append(r.ids, user.ID)
+	return nil
+}
+
+// Audit logs the users revealed since the last call
+func (r *piiReader) Audit(ctx context.Context, action string) {
+	audit.PIIRead(ctx, action, r.ids)
+	r.ids = r.ids[:0]
+}
+
+// checkEmailFilter rejects filtering by email for callers that
+// may not read emails, the result would confirm the address
+func checkEmailFilter(ctx context.Context, email string) error {
+	if email != "" && !auth.PrincipalFrom(ctx).HasScope(auth.ScopeUsersReadPII) {
+		return entity.NewForbiddenError("filtering by email_address requires the %s scope", auth.ScopeUsersReadPII)
+	}
+	return nil
+}
+
+// maskEmail keeps the first character of the local part and the
+// domain, e.g. j***@example.com
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	first, _ := utf8.DecodeRuneInString(local)
+	return string(first) + "***@" + domain
+}
@@ -0,0 +1,71 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+)
+
+type relativesRepo interface {
+	GetByIDs(ctx context.Context, ids []int64) ([]entity.User, error)
+	GetChildren(ctx context.Context, parentIDs []int64) ([]entity.User, error)
+}
+
+type relativesCryptor interface {
+	Decrypt(user *entity.User) error
+}
+
+// relativesUsecase loads the parents and children of many users at
+// once, so nested reads cost one query per level
+type relativesUsecase struct {
+	repo    relativesRepo
+	cryptor relativesCryptor
+}
+
+func NewRelativesUsecase(repo relativesRepo, cryptor relativesCryptor) *relativesUsecase {
+	return &relativesUsecase{
+		repo:    repo,
+		cryptor: cryptor,
+	}
+}
+
+// Users returns the users with the given ids, by id. Their emails
+// are only read when fields, comma separated, has email_address
+func (u *relativesUsecase) Users(ctx context.Context, ids []int64, fields string) (map[int64]entity.User, error) {
+	users, err := u.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	pii := newPIIReader(ctx, u.cryptor).forFields(fields)
+	byID := make(map[int64]entity.User, len(users))
+	for _, user := range users {
+		if err := pii.Reveal(&user); err != nil {
+			return nil, err
+		}
+		byID[user.ID] = user
+	}
+	pii.Audit(ctx, "users.get")
+
+	return byID, nil
+}
+
+// Children returns the children of the given users, by parent id.
+// Their emails are only read when fields has email_address
+func (u *relativesUsecase) Children(ctx context.Context, parentIDs []int64, fields string) (map[int64][]entity.User, error) {
+	users, err := u.repo.GetChildren(ctx, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	pii := newPIIReader(ctx, u.cryptor).forFields(fields)
+	byParent := make(map[int64][]entity.User, len(parentIDs))
+	for _, user := range users {
+		if err := pii.Reveal(&user); err != nil {
+			return nil, err
+		}
+		byParent[*user.ParentUserID] = append(byParent[*user.ParentUserID], user)
+	}
+	pii.Audit(ctx, "users.children")
+
+	return byParent, nil
+}
@@ -47,7 +47,21 @@ func NewSearchUsecase(repo searchRepo, cache searchCache, cryptor searchCryptor)
 }
 
 func (u *searchUsecase) Execute(ctx context.Context, input SearchInput) ([]entity.User, error) {
-	key := input.String()
+	if err := checkEmailFilter(ctx, input.Email); err != nil {
+		return nil, err
+	}
+
+	// the writes bump the version, see invalidateSearches
+	version, err := u.cache.Get(ctx, searchVersionKey)
+	if err != nil {
+		return nil, err
+	}
+	v := "0"
+	if version != nil {
+		v = *version
+	}
+	key := "search:" + v + ":" + input.String()
+
 	cached, err := u.cache.Get(ctx, key)
 	if err != nil {
 		return nil, err
@@ -58,7 +72,7 @@ func (u *searchUsecase) Execute(ctx context.Context, input SearchInput) ([]entit
 		if err := json.Unmarshal([]byte(*cached), &users); err != nil {
 			return nil, err
 		}
-		decryptedUsers, err := u.decrypt(users)
+		decryptedUsers, err := u.decrypt(ctx, users, input.Fields)
 		if err != nil {
 			return nil, err
 		}
@@ -83,16 +97,16 @@ func (u *searchUsecase) Execute(ctx context.Context, input SearchInput) ([]entit
 		defer wg.Done()
 		toCache, err := json.Marshal(users)
 		if err != nil {
-			slog.Error("search-usecase", slog.Group("Execute", "marshal to cache", err))
+			slog.ErrorContext(ctx, "search-usecase", slog.Group("Execute", "marshal to cache", err))
 			return
 		}
 		if err := u.cache.Set(ctx, key, string(toCache), searchCacheExp); err != nil {
-			slog.Error("search-usecase", slog.Group("Execute", "set user to cache", err))
+			slog.ErrorContext(ctx, "search-usecase", slog.Group("Execute", "set user to cache", err))
 			return
 		}
 	}()
 
-	decryptedUsers, err := u.decrypt(users)
+	decryptedUsers, err := u.decrypt(ctx, users, input.Fields)
 	if err != nil {
 		return nil, err
 	}
@@ -102,14 +116,16 @@ func (u *searchUsecase) Execute(ctx context.Context, input SearchInput) ([]entit
 	return decryptedUsers, nil
 }
 
-func (u *searchUsecase) decrypt(users []entity.User) ([]entity.User, error) {
+func (u *searchUsecase) decrypt(ctx context.Context, users []entity.User, fields string) ([]entity.User, error) {
+	pii := newPIIReader(ctx, u.cryptor).forFields(fields)
 	dec := make([]entity.User, len(users))
 	for i, user := range users {
-		if err := u.cryptor.Decrypt(&user); err != nil {
+		if err := pii.Reveal(&user); err != nil {
 			return nil, err
 		}
 		dec[i] = user
 	}
+	pii.Audit(ctx, "users.search")
 	return dec, nil
 }
 
@@ -129,6 +145,6 @@ func (s *SearchInput) SortedFields() []string {
 
 func (s *SearchInput) String() string {
 	fields := strings.Join(s.SortedFields(), ",")
-	str := fmt.Sprintf("%s-%s-%s-%s", s.FirstName, s.FirstName, s.Email, fields)
+	str := fmt.Sprintf("%s-%s-%s-%s", s.FirstName, s.LastName, s.Email, fields)
 	return str
 }
@@ -0,0 +1,5 @@
+package usecase
+
+import "go.opentelemetry.io/otel"
+
+var tracer = otel.Tracer("api/internal/usecase")
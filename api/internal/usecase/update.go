@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"api/internal/entity"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type updateCryptor interface {
+	Encrypt(user *entity.User) error
+	Decrypt(user *entity.User) error
+}
+
+type updateRepo interface {
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	Update(ctx context.Context, user entity.User, version *int64) (*entity.User, error)
+}
+
+const (
+	updateCacheExp = time.Minute * 15
+)
+
+type updateUsecase struct {
+	repo    updateRepo
+	cache   userCache
+	cryptor updateCryptor
+}
+
+func NewUpdateUsecase(repo updateRepo, cache userCache, cryptor updateCryptor) *updateUsecase {
+	return &updateUsecase{
+		repo:    repo,
+		cache:   cache,
+		cryptor: cryptor,
+	}
+}
+
+// Execute applies the non nil fields of input to the user. When
+// version is given it must match the stored version. Returns nil
+// if the user does not exist
+func (u *updateUsecase) Execute(ctx context.Context, id string, input UpdateInput, version *int64) (*entity.User, error) {
+	user, err := u.repo.GetByID(ctx, id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	if version != nil && *version != user.Version {
+		return nil, entity.NewPreconditionError("user %d was modified, current version is %d", user.ID, user.Version)
+	}
+
+	if input.FirstName != nil {
+		user.FirstName = *input.FirstName
+	}
+	if input.LastName != nil {
+		user.LastName = *input.LastName
+	}
+	if input.Email != nil {
+		user.Email = *input.Email
+		if err := u.cryptor.Encrypt(user); err != nil {
+			return nil, err
+		}
+	}
+
+	// the version just read guards against concurrent writes
+	// between the read above and the update
+	updated, err := u.repo.Update(ctx, *user, &user.Version)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+
+	if err := setUserCache(ctx, u.cache, *updated, updateCacheExp); err != nil {
+		slog.ErrorContext(ctx, "update-usecase", slog.Group("Execute", "set user to cache", err))
+	}
+	if err := invalidateSearches(ctx, u.cache); err != nil {
+		slog.ErrorContext(ctx, "update-usecase", slog.Group("Execute", "invalidate searches", err))
+	}
+
+	pii := newPIIReader(ctx, u.cryptor)
+	if err := pii.Reveal(updated); err != nil {
+		return nil, err
+	}
+	pii.Audit(ctx, "users.update")
+
+	return updated, nil
+}
+
+type UpdateInput struct {
+	FirstName *string
+	LastName  *string
+	Email     *string
+}
@@ -1,84 +1,308 @@
 package usecase
 
 import (
+	"api/internal/adapter"
 	"api/internal/entity"
+	"api/internal/metrics"
 	"context"
+	"desafio/pkg/producer"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type upsertQueue interface {
-	Consume(ch chan<- string) error
+	Consume(ch chan<- adapter.Message, prefetch int) error
 }
 
 type upsertRepo interface {
-	Upsert(ctx context.Context, user entity.User) error
+	Upsert(ctx context.Context, user entity.User) (*entity.User, bool, error)
 }
 
 type upsertCache interface {
 	Get(ctx context.Context, key string) (*string, error)
 	Set(ctx context.Context, key string, data string, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// upsertDedup records the ids of the processed messages
+type upsertDedup interface {
+	Exists(ctx context.Context, key string) (bool, error)
+	Set(ctx context.Context, key string, data string, ttl time.Duration) error
 }
 
 const (
 	upsertCacheExp = time.Minute * 15
+
+	// upsertWorkerBuffer is how many users may wait for each worker
+	// before the consumer stops reading the queue
+	upsertWorkerBuffer = 64
+
+	// upsertPrefetch is how many messages may be processed at a
+	// time, they are only acknowledged once all their users are
+	upsertPrefetch = 64
 )
 
 type upsertUsecase struct {
 	queue    upsertQueue
 	userRepo upsertRepo
 	cache    upsertCache
+	dedup    upsertDedup
+	dedupTTL time.Duration
+	workers  int
+
+	running atomic.Bool
 }
 
-func NewUpsertUsecase(queue upsertQueue, repo upsertRepo, cache upsertCache) *upsertUsecase {
+// NewUpsertUsecase creates the queue consumer, upserting with the
+// given number of workers. The message ids are remembered for
+// dedupTTL, a message seen in that time is skipped
+func NewUpsertUsecase(queue upsertQueue, repo upsertRepo, cache upsertCache, dedup upsertDedup, dedupTTL time.Duration, workers int) *upsertUsecase {
 	return &upsertUsecase{
 		queue:    queue,
 		userRepo: repo,
 		cache:    cache,
+		dedup:    dedup,
+		dedupTTL: dedupTTL,
+		workers:  max(workers, 1),
 	}
 }
 
+// Execute consumes the queue until it is closed. The users of the
+// messages are upserted by the workers, every user always going to
+// the same worker so the changes to a user are applied in order. A
+// message is acknowledged once all its users are upserted, see
+// complete
 func (u *upsertUsecase) Execute(ctx context.Context) error {
-	messageChannel := make(chan string)
-	if err := u.queue.Consume(messageChannel); err != nil {
+	messageChannel := make(chan adapter.Message)
+	if err := u.queue.Consume(messageChannel, upsertPrefetch); err != nil {
 		return fmt.Errorf("failed to consume messages: %v", err)
 	}
-	defer close(messageChannel)
 
+	// the queue closes the channel when the consumer stops
+	u.running.Store(true)
+	defer u.running.Store(false)
+
+	workers := make([]chan upsertJob, u.workers)
 	var wg sync.WaitGroup
+	for i := range workers {
+		workers[i] = make(chan upsertJob, upsertWorkerBuffer)
+		wg.Add(1)
+		go func(jobs <-chan upsertJob) {
+			defer wg.Done()
+			for job := range jobs {
+				u.upsert(job)
+			}
+		}(workers[i])
+	}
+
 	for msg := range messageChannel {
+		// continues the trace started by the producer
+		msgCtx := otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+		msgCtx, span := tracer.Start(msgCtx, "upsert.message",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.message.id", msg.ID),
+				attribute.String("file.name", msg.Headers[producer.FilenameHeader]),
+			))
+
+		if u.duplicate(msgCtx, msg.ID) {
+			metrics.ConsumerMessages.WithLabelValues("duplicate").Inc()
+			span.SetAttributes(attribute.Bool("duplicate", true))
+			span.End()
+			ack(msgCtx, msg)
+			continue
+		}
+
 		var users []entity.User
-		if err := json.Unmarshal([]byte(msg), &users); err != nil {
-			slog.Error("upsert-usecase", slog.Group("Execute", "unmarshal", err))
+		if err := json.Unmarshal([]byte(msg.Body), &users); err != nil {
+			slog.ErrorContext(msgCtx, "upsert-usecase", slog.Group("Execute", "unmarshal", err))
+			metrics.ConsumerMessages.WithLabelValues("invalid").Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			nack(msgCtx, msg, false)
+			continue
+		}
+		span.SetAttributes(attribute.Int("users", len(users)))
+
+		if len(users) == 0 {
+			span.End()
+			u.complete(msgCtx, msg, 0)
 			continue
 		}
 
+		b := &upsertBatch{
+			span: span,
+			complete: func(failed int64) {
+				u.complete(msgCtx, msg, failed)
+			},
+		}
+		b.pending.Store(int64(len(users)))
 		for _, user := range users {
-			wg.Add(1)
-			if err := u.userRepo.Upsert(ctx, user); err != nil {
-				slog.Error("upsert-usecase", slog.Group("Execute", "upsert", err))
-				continue
-			}
-			go func(user entity.User) {
-				defer wg.Done()
-				toCache, err := json.Marshal(user)
-				if err != nil {
-					slog.Error("upsert-usecase", slog.Group("Execute", "cache marshal", err))
-					return
-				}
-				key := user.Email
-				if err := u.cache.Set(ctx, key, string(toCache), upsertCacheExp); err != nil {
-					slog.Error("upsert-usecase", slog.Group("Execute", "cache set", err))
-					return
-				}
-			}(user)
+			workers[workerOf(user.ID, len(workers))] <- upsertJob{ctx: msgCtx, user: user, batch: b}
 		}
 	}
 
+	for _, jobs := range workers {
+		close(jobs)
+	}
 	wg.Wait()
 
 	return nil
 }
+
+// upsert stores a user, its failure is logged and counted
+func (u *upsertUsecase) upsert(job upsertJob) {
+	ctx, user := job.ctx, job.user
+
+	stored, changed, err := u.userRepo.Upsert(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "upsert", err))
+		metrics.ConsumerUsers.WithLabelValues("failed").Inc()
+		job.batch.done(err)
+		return
+	}
+	metrics.ConsumerUsers.WithLabelValues("upserted").Inc()
+
+	// a newer stored user is kept as is, the cache gets the user
+	// as stored and only when the write changed it
+	if !changed {
+		job.batch.done(nil)
+		return
+	}
+
+	toCache, err := json.Marshal(stored)
+	if err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "cache marshal", err))
+	} else if err := u.cache.Set(ctx, stored.Email, string(toCache), upsertCacheExp); err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "cache set", err))
+	}
+	if err := invalidateSearches(ctx, u.cache); err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "invalidate searches", err))
+	}
+
+	job.batch.done(nil)
+}
+
+// workerOf spreads the users over n workers by id. The upsert
+// conflicts on email_address, but the emails are encrypted with a
+// random IV, so two rows only share one when they are copies of the
+// same encrypted user, which have the same id too. Sharding by id
+// thus keeps every pair of rows that may conflict on one worker
+func workerOf(id int64, n int) int {
+	h := fnv.New32a()
+	binary.Write(h, binary.LittleEndian, id)
+	return int(h.Sum32() % uint32(n))
+}
+
+type upsertJob struct {
+	ctx   context.Context
+	user  entity.User
+	batch *upsertBatch
+}
+
+// upsertBatch tracks the users of a message spread over the workers,
+// its span ends with the last of them, which calls complete
+type upsertBatch struct {
+	span     trace.Span
+	complete func(failed int64)
+	pending  atomic.Int64
+	failed   atomic.Int64
+}
+
+func (b *upsertBatch) done(err error) {
+	if err != nil {
+		b.failed.Add(1)
+		b.span.RecordError(err)
+	}
+	if b.pending.Add(-1) > 0 {
+		return
+	}
+
+	failed := b.failed.Load()
+	if failed > 0 {
+		b.span.SetStatus(codes.Error, fmt.Sprintf("%d users failed", failed))
+	}
+	b.span.End()
+	b.complete(failed)
+}
+
+// complete settles a message once all its users were handled. When
+// they all succeeded its id is recorded and it is acknowledged,
+// otherwise it is delivered again, once, before being dropped. The
+// users already upserted are upserted again, which changes nothing
+func (u *upsertUsecase) complete(ctx context.Context, msg adapter.Message, failed int64) {
+	if failed == 0 {
+		metrics.ConsumerMessages.WithLabelValues("processed").Inc()
+		u.record(ctx, msg.ID)
+		ack(ctx, msg)
+		return
+	}
+
+	requeue := !msg.Redelivered
+	if requeue {
+		metrics.ConsumerMessages.WithLabelValues("requeued").Inc()
+	} else {
+		metrics.ConsumerMessages.WithLabelValues("failed").Inc()
+	}
+	nack(ctx, msg, requeue)
+}
+
+// duplicate reports whether the message id was recorded, which
+// happens once all its users are upserted. Messages without id, or
+// whose id cannot be checked, are processed
+func (u *upsertUsecase) duplicate(ctx context.Context, id string) bool {
+	if id == "" {
+		return false
+	}
+
+	seen, err := u.dedup.Exists(ctx, messageKey(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "dedup", err))
+		return false
+	}
+	return seen
+}
+
+// record remembers the message id for dedupTTL
+func (u *upsertUsecase) record(ctx context.Context, id string) {
+	if id == "" {
+		return
+	}
+	if err := u.dedup.Set(ctx, messageKey(id), time.Now().UTC().Format(time.RFC3339), u.dedupTTL); err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "dedup record", err))
+	}
+}
+
+func messageKey(id string) string {
+	return "message:" + id
+}
+
+func ack(ctx context.Context, msg adapter.Message) {
+	if err := msg.Ack(); err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "ack", err))
+	}
+}
+
+func nack(ctx context.Context, msg adapter.Message, requeue bool) {
+	if err := msg.Nack(requeue); err != nil {
+		slog.ErrorContext(ctx, "upsert-usecase", slog.Group("Execute", "nack", err))
+	}
+}
+
+// Running reports whether Execute is consuming messages
+func (u *upsertUsecase) Running() bool {
+	return u.running.Load()
+}
@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"api/internal/broadcast"
+	"api/internal/entity"
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// watchBuffer is how many changes a watcher may fall behind
+// before it is dropped
+const watchBuffer = 256
+
+type watchHub interface {
+	SubscribeAfter(buffer int, lastID string) (*broadcast.Subscription, bool)
+}
+
+type watchCryptor interface {
+	Decrypt(user *entity.User) error
+}
+
+type watchUsecase struct {
+	hub     watchHub
+	cryptor watchCryptor
+}
+
+func NewWatchUsecase(hub watchHub, cryptor watchCryptor) *watchUsecase {
+	return &watchUsecase{
+		hub:     hub,
+		cryptor: cryptor,
+	}
+}
+
+// Execute calls fn with every change applied from now on matching
+// filter, until ctx is done or fn fails. When resuming, the changes
+// missed since filter.LastEventID come first, or a ChangesLost
+// change if they are no longer kept. ChangesLost is also sent when
+// the changes stopped being received for a while. Emails follow the
+// same rules as the other reads
+func (u *watchUsecase) Execute(ctx context.Context, filter WatchFilter, fn func(change entity.UserChange) error) error {
+	sub, resumed := u.hub.SubscribeAfter(watchBuffer, filter.LastEventID)
+	defer sub.Close()
+
+	if !resumed {
+		lost := entity.UserChange{Type: entity.ChangesLost, At: time.Now().UTC()}
+		if err := fn(lost); err != nil {
+			return err
+		}
+	}
+
+	pii := newPIIReader(ctx, u.cryptor)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-sub.C():
+			if !ok {
+				return sub.Err()
+			}
+			// the changes lost may concern any user
+			if change.Type == entity.ChangesLost {
+				if err := fn(change); err != nil {
+					return err
+				}
+				continue
+			}
+			if !filter.Match(change.User) {
+				continue
+			}
+			if err := pii.Reveal(&change.User); err != nil {
+				return err
+			}
+			pii.Audit(ctx, "users.watch")
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WatchFilter selects the changes sent to a watcher, the empty
+// filter selects them all
+type WatchFilter struct {
+	IDs []int64
+	// NamePrefix matches the start of the first or the last name,
+	// ignoring case
+	NamePrefix string
+	// LastEventID resumes after the change with this id
+	LastEventID string
+}
+
+func (f *WatchFilter) Match(user entity.User) bool {
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, user.ID) {
+		return false
+	}
+	if f.NamePrefix != "" && !hasPrefixFold(user.FirstName, f.NamePrefix) && !hasPrefixFold(user.LastName, f.NamePrefix) {
+		return false
+	}
+	return true
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
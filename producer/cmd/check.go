@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"desafio/pkg/producer"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// errAnomalies fails a validation that found anomalies
+var errAnomalies = errors.New("the files have anomalies")
+
+// validate prints the validation report of the files
+func validate(ctx context.Context, files []string, opts producer.ReaderOptions) error {
+	report, err := producer.Validate(ctx, files, opts)
+	if err != nil {
+		return err
+	}
+
+	report.Write(os.Stdout)
+	if !report.OK() {
+		return errAnomalies
+	}
+	return nil
+}
+
+// dryRun sends the files through the whole pipeline, encryption
+// included, but drops the batches instead of publishing them
+func dryRun(ctx context.Context, files []string, opts producer.ReaderOptions, parser producer.Parser, workers, parallel, batchSize int) error {
+	observer := &countObserver{next: producer.LogObserver{}}
+	err := producer.NewFilesProducer(files, opts, parser, producer.Discard).
+		WithObserver(observer).
+		WithWorkers(workers).
+		WithParallelism(parallel).
+		Produce(ctx, batchSize)
+
+	fmt.Printf("dry run, nothing was published: %d users in %d batches, %d rejected records\n",
+		observer.users.Load(), observer.batches.Load(), observer.rejected.Load())
+	return err
+}
+
+// countObserver counts the outcome of a run, forwarding
+// every event to next
+type countObserver struct {
+	next producer.Observer
+
+	users    atomic.Int64
+	batches  atomic.Int64
+	rejected atomic.Int64
+}
+
+func (o *countObserver) Rejected(r producer.Record, err error) {
+	o.rejected.Add(1)
+	o.next.Rejected(r, err)
+}
+
+func (o *countObserver) Published(users int) {
+	o.batches.Add(1)
+	o.users.Add(int64(users))
+	o.next.Published(users)
+}
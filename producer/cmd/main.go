@@ -1,55 +1,176 @@
 package main
 
 import (
-	"desafio/internal/producer"
-	"desafio/internal/service"
-	"flag"
+	"context"
+	"desafio/internal/config"
+	"desafio/internal/health"
+	"desafio/internal/metrics"
+	"desafio/pkg/producer"
+	"desafio/pkg/service"
+	"desafio/pkg/tracing"
+	"errors"
 	"log"
+	"net/http"
 	"os"
-
-	"github.com/joho/godotenv"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 )
 
 func main() {
-	err := godotenv.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if cfg.PrintRequested() {
+		cfg.Print(os.Stdout)
+	}
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+	if cfg.PrintRequested() {
+		return
 	}
 
-	var (
-		// AMQP connection settings
-		amqpURL   = os.Getenv("AMQP_URL")
-		amqpQueue = os.Getenv("AMQP_QUEUE")
+	ctx := context.Background()
 
-		// 32 bytes hex key
-		cryptorKey = os.Getenv("CRYPTOR_KEY")
-	)
+	shutdownTracing, err := tracing.Setup(ctx, "producer", cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
 
-	batchSize := flag.Int("b", 100, "Batch size used to send users to the queue")
-	file := flag.String("f", "users.csv", "CSV file path")
+	delimiter, err := producer.ParseDelimiter(cfg.Delimiter)
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
 
-	flag.Parse()
+	if cfg.Watch != "" && (cfg.DryRun || cfg.Validate) {
+		log.Fatalf("Invalid config:\n-dry-run and -validate do not apply to -watch")
+	}
 
-	reader, err := producer.NewCsvReader(*file)
-	if err != nil {
-		log.Fatal(err)
+	opts := producer.ReaderOptions{
+		Format:     producer.Format(cfg.Format),
+		Delimiter:  delimiter,
+		LazyQuotes: cfg.LazyQuotes,
 	}
-	defer reader.Close()
 
-	cryptor, err := service.NewCryptor(cryptorKey)
+	var files []string
+	if cfg.Watch == "" {
+		files, err = producer.ListFiles(cfg.File)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// each check reads the files on its own, the standard input
+	// can only be read once
+	if cfg.Validate && cfg.DryRun && slices.Contains(files, producer.Stdin) {
+		log.Fatalf("Invalid config:\n-dry-run and -validate cannot be combined on the standard input")
+	}
+
+	cryptor, err := service.NewCryptor(cfg.CryptorKey)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	parser := producer.NewCsvUserParser(cryptor)
 
-	adapter, err := producer.NewRabbitMQAdapter(amqpURL, amqpQueue)
+	// checks run without the queue and publish nothing
+	if cfg.Validate || cfg.DryRun {
+		var errs []error
+		if cfg.Validate {
+			errs = append(errs, validate(ctx, files, opts))
+		}
+		if cfg.DryRun {
+			errs = append(errs, dryRun(ctx, files, opts, parser, cfg.Workers, cfg.Parallel, cfg.BatchSize))
+		}
+		err := errors.Join(errs...)
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("failed to flush traces: %v", err)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	adapter, err := producer.NewRabbitMQAdapter(cfg.AMQPURL, cfg.AMQPQueue)
 	if err != nil {
 		log.Fatalf("Failed to initialize RabbitMQ adapter: %v", err)
 	}
 	defer adapter.Close()
 
-	up := producer.NewUserProducer(reader, parser, adapter)
+	var ledger producer.Ledger
+	if cfg.StateFile != "" {
+		ledger, err = producer.NewFileLedger(cfg.StateFile)
+		if err != nil {
+			log.Fatalf("Failed to load the state file: %v", err)
+		}
+	}
+
+	observer := metrics.NewRunObserver(producer.LogObserver{})
+	produce := func(ctx context.Context, files []string) error {
+		up := producer.NewFilesProducer(files, opts, parser, adapter).
+			WithObserver(observer).
+			WithWorkers(cfg.Workers).
+			WithParallelism(cfg.Parallel)
+		if ledger != nil {
+			up = up.WithLedger(ledger)
+		}
+
+		err := up.Produce(ctx, cfg.BatchSize)
+
+		if cfg.PushgatewayURL != "" {
+			if err := observer.Push(cfg.PushgatewayURL, err); err != nil {
+				log.Printf("failed to push metrics: %v", err)
+			}
+		}
+		return err
+	}
+
+	if cfg.Watch != "" {
+		err = watch(ctx, cfg, adapter, produce)
+	} else {
+		err = produce(ctx, files)
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("failed to flush traces: %v", err)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type queueStatus interface {
+	Status() error
+}
+
+// watch publishes the files landing in cfg.Watch until the process
+// is interrupted, serving the health endpoint meanwhile
+func watch(ctx context.Context, cfg *config.Config, queue queueStatus, produce func(ctx context.Context, files []string) error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	watcher := producer.NewDirWatcher(cfg.Watch, cfg.PollInterval, func(ctx context.Context, filename string) error {
+		return produce(ctx, []string{filename})
+	})
+
+	server := &http.Server{
+		Addr: cfg.HealthPort,
+		Handler: health.Handler(map[string]func() error{
+			"queue":   queue.Status,
+			"watcher": watcher.Status,
+		}),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to serve the health endpoint: %v", err)
+		}
+	}()
+	defer server.Close()
 
-	up.Produce(*batchSize)
+	log.Printf("watching %s", cfg.Watch)
+	return watcher.Run(ctx)
 }
@@ -0,0 +1,65 @@
+package config
+
+import (
+	loader "desafio/pkg/config"
+	"io"
+	"time"
+)
+
+// Config holds every setting of the producer, see desafio/pkg/config
+// for the meaning of the tags
+type Config struct {
+	AMQPURL   string `config:"AMQP_URL" validate:"required,amqpurl" usage:"RabbitMQ URL"`
+	AMQPQueue string `config:"AMQP_QUEUE" validate:"required" usage:"queue the users are published to"`
+
+	CryptorKey string `config:"CRYPTOR_KEY" secret:"true" validate:"required,hexkey32" usage:"32 bytes hex key used to encrypt emails"`
+
+	PushgatewayURL string `config:"PUSHGATEWAY_URL" validate:"httpurl" usage:"pushgateway compatible endpoint for the run metrics"`
+	OTLPEndpoint   string `config:"OTEL_EXPORTER_OTLP_ENDPOINT" validate:"httpurl" usage:"OTLP/HTTP collector endpoint, tracing export is disabled when empty"`
+
+	BatchSize int    `config:"BATCH_SIZE" flag:"b" default:"100" validate:"positive" usage:"Batch size used to send users to the queue"`
+	File      string `config:"FILE" flag:"f" default:"users.csv" validate:"required" usage:"CSV, TSV or JSONL file, optionally gzip or zstd compressed, directory or glob pattern of such files, - for the standard input"`
+	Workers   int    `config:"WORKERS" flag:"w" usage:"batches of a file parsed and encrypted at once, 0 for one per CPU"`
+	Parallel  int    `config:"PARALLEL_FILES" flag:"p" default:"1" validate:"positive" usage:"files published at once"`
+	// StateFile records the published files, which are skipped by
+	// the next runs
+	StateFile string `config:"STATE_FILE" usage:"JSON file recording the published files to skip them on the next runs, disabled when empty"`
+
+	// DryRun and Validate check the files without publishing them
+	DryRun   bool `config:"DRY_RUN" usage:"read, parse and encrypt the files without publishing them"`
+	Validate bool `config:"VALIDATE" usage:"print a report of the anomalies of the files without publishing them, exits with 1 when there are some"`
+
+	// Watch keeps the producer running, publishing the files
+	// landing in the directory
+	Watch        string        `config:"WATCH" usage:"directory watched for new files, published as they land, -f is ignored"`
+	PollInterval time.Duration `config:"POLL_INTERVAL" default:"5s" validate:"positive" usage:"how often the watched directory is scanned when it cannot be watched for changes"`
+	HealthPort   string        `config:"HEALTH_PORT" default:":8081" validate:"required,hostport" usage:"address the health endpoint listens on in watch mode"`
+
+	// Format is guessed from the file extension when empty
+	Format     string `config:"FORMAT" usage:"file format, one of csv, tsv or jsonl, guessed from the file extension when empty"`
+	Delimiter  string `config:"DELIMITER" usage:"field delimiter of CSV files, a single character or \\t, comma by default"`
+	LazyQuotes bool   `config:"LAZY_QUOTES" usage:"accept quotes in unquoted fields and unescaped quotes in quoted fields of CSV files"`
+
+	printConfig bool
+}
+
+// Load reads the config from the defaults, the file given by
+// -config or CONFIG_FILE, the environment (and an optional .env)
+// and the command line flags in args, the last one wins. All the
+// invalid values are reported at once
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	printConfig, err := loader.Load(cfg, args)
+	cfg.printConfig = printConfig
+	return cfg, err
+}
+
+// PrintRequested reports whether -print-config was given
+func (c *Config) PrintRequested() bool {
+	return c.printConfig
+}
+
+// Print writes the effective config with the secrets redacted
+func (c *Config) Print(w io.Writer) {
+	loader.Write(c, w)
+}
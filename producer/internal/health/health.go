@@ -0,0 +1,48 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves GET /health with the status of every check,
+// responding with 503 if any of them is down
+func Handler(checks map[string]func() error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := Response{
+			Status: "up",
+			Checks: make(map[string]Check, len(checks)),
+		}
+		status := http.StatusOK
+		for name, check := range checks {
+			result := Check{Status: "up"}
+			if err := check(); err != nil {
+				result = Check{Status: "down", Error: err.Error()}
+				resp.Status = "down"
+				status = http.StatusServiceUnavailable
+			}
+			resp.Checks[name] = result
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	})
+	return mux
+}
+
+type Response struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"desafio/pkg/producer"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	namespace = "challenge"
+	subsystem = "producer"
+	job       = "producer"
+)
+
+// runObserver collects the metrics of a single producer run
+// while forwarding every event to the next observer
+type runObserver struct {
+	next producer.Observer
+
+	registry  *prometheus.Registry
+	published prometheus.Counter
+	rejected  prometheus.Counter
+	batches   prometheus.Counter
+	duration  prometheus.Gauge
+	success   prometheus.Gauge
+	started   time.Time
+}
+
+// NewRunObserver creates an observer that records the metrics
+// of the run, the events are also sent to next
+func NewRunObserver(next producer.Observer) *runObserver {
+	o := &runObserver{
+		next:     next,
+		registry: prometheus.NewRegistry(),
+		started:  time.Now(),
+		published: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "users_published_total",
+			Help: "Users published to the queue.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "records_rejected_total",
+			Help: "Records that could not be parsed.",
+		}),
+		batches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "batches_published_total",
+			Help: "Batches published to the queue.",
+		}),
+		duration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "run_duration_seconds",
+			Help: "Duration of the last run.",
+		}),
+		success: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: subsystem,
+			Name: "run_success",
+			Help: "Whether the last run finished without errors.",
+		}),
+	}
+	o.registry.MustRegister(o.published, o.rejected, o.batches, o.duration, o.success)
+	return o
+}
+
+func (o *runObserver) Rejected(r producer.Record, err error) {
+	o.rejected.Inc()
+	o.next.Rejected(r, err)
+}
+
+func (o *runObserver) Published(users int) {
+	o.batches.Inc()
+	o.published.Add(float64(users))
+	o.next.Published(users)
+}
+
+// Push sends the run metrics to a pushgateway compatible endpoint,
+// runErr is the outcome of the run
+func (o *runObserver) Push(url string, runErr error) error {
+	o.duration.Set(time.Since(o.started).Seconds())
+	if runErr == nil {
+		o.success.Set(1)
+	}
+	return push.New(url, job).Gatherer(o.registry).Push()
+}
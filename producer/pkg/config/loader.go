@@ -0,0 +1,326 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// The fields of a config struct are described by tags:
+//
+//	config:"NAME"         env var name, the file key is its lowercase form
+//	                      and the flag its lowercase form with dashes
+//	flag:"f"              overrides the flag name
+//	default:"value"       value used when nothing else sets the field
+//	usage:"text"          flag usage
+//	secret:"true"         redacted when printed
+//	validate:"a,b"        validations run after loading, see validators
+//
+// Fields are strings, ints, bools or time.Durations (as in 30s).
+// The flags of bool fields may be given alone, -name sets true.
+// Sources are applied in this order, the last one wins: defaults,
+// config file, environment (including an optional .env) and flags
+
+// field is a config field resolved from its struct tags
+type field struct {
+	name     string
+	flag     string
+	def      string
+	usage    string
+	secret   bool
+	validate []string
+	value    reflect.Value
+}
+
+func (f field) fileKey() string {
+	return strings.ToLower(f.name)
+}
+
+// Load fills cfg, which must be a pointer to a struct, from every
+// source and validates it, joining all the errors found. It also
+// reports whether printing the config was requested
+func Load(cfg any, args []string) (printConfig bool, err error) {
+	fields := fieldsOf(cfg)
+
+	fs := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML (.yaml, .yml) or TOML (.toml) config file")
+	printFlag := fs.Bool("print-config", false, "print the effective config, secrets redacted, and exit")
+
+	flagValues := make(map[string]*flagValue, len(fields))
+	for _, f := range fields {
+		flagValues[f.name] = &flagValue{isBool: f.value.Kind() == reflect.Bool}
+		fs.Var(flagValues[f.name], f.flag, f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if f.def != "" {
+			errs = append(errs, set(f, f.def))
+		}
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return *printFlag, err
+		}
+		for _, f := range fields {
+			if v, ok := values[f.fileKey()]; ok {
+				errs = append(errs, set(f, fmt.Sprint(v)))
+			}
+		}
+	}
+
+	// .env is optional, the environment may come from the container
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return *printFlag, fmt.Errorf("failed to load .env: %w", err)
+	}
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.name); ok && v != "" {
+			errs = append(errs, set(f, v))
+		}
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name {
+				errs = append(errs, set(f, flagValues[f.name].value))
+			}
+		}
+	})
+
+	for _, f := range fields {
+		errs = append(errs, validateField(f)...)
+	}
+
+	return *printFlag, errors.Join(errs...)
+}
+
+// flagValue holds the value given to a flag, the flags of bool
+// fields may be given alone, as in -name for -name=true
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(s string) error {
+	v.value = s
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func fieldsOf(cfg any) []field {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("config")
+		if name == "" {
+			continue
+		}
+
+		f := field{
+			name:   name,
+			flag:   sf.Tag.Get("flag"),
+			def:    sf.Tag.Get("default"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		}
+		if f.flag == "" {
+			f.flag = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+		}
+		if rules := sf.Tag.Get("validate"); rules != "" {
+			f.validate = strings.Split(rules, ",")
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func set(f field, raw string) error {
+	if f.value.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%s: must be a duration such as 30s or 24h, got %q", f.name, raw)
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s: must be an integer, got %q", f.name, raw)
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: must be a boolean, got %q", f.name, raw)
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("%s: unsupported config type %s", f.name, f.value.Kind())
+	}
+	return nil
+}
+
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	values := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	lower := make(map[string]any, len(values))
+	for k, v := range values {
+		lower[strings.ToLower(k)] = v
+	}
+	return lower, nil
+}
+
+// validators check a single field value, empty strings are only
+// rejected by required
+var validators = map[string]func(v reflect.Value) error{
+	"required": func(v reflect.Value) error {
+		if v.IsZero() {
+			return errors.New("is required")
+		}
+		return nil
+	},
+	"hexkey32": func(v reflect.Value) error {
+		b, err := hex.DecodeString(v.String())
+		if err != nil || len(b) != 32 {
+			return errors.New("must be a 32 bytes hex encoded key")
+		}
+		return nil
+	},
+	"hostport": func(v reflect.Value) error {
+		_, port, err := net.SplitHostPort(v.String())
+		if err != nil {
+			return errors.New("must be in the host:port form")
+		}
+		return validPort(port)
+	},
+	"port": func(v reflect.Value) error {
+		return validPort(strconv.FormatInt(v.Int(), 10))
+	},
+	"positive": func(v reflect.Value) error {
+		if v.Int() <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	},
+	"amqpurl": func(v reflect.Value) error {
+		return validURL(v.String(), "amqp", "amqps")
+	},
+	"httpurl": func(v reflect.Value) error {
+		return validURL(v.String(), "http", "https")
+	},
+	"loglevel": func(v reflect.Value) error {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(strings.ToUpper(v.String()))); err != nil {
+			return errors.New("must be one of debug, info, warn or error")
+		}
+		return nil
+	},
+}
+
+func validateField(f field) []error {
+	var errs []error
+	for _, rule := range f.validate {
+		if rule != "required" && f.value.Kind() == reflect.String && f.value.IsZero() {
+			continue
+		}
+		validate, ok := validators[rule]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown validation %q", f.name, rule))
+			continue
+		}
+		if err := validate(f.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	return errs
+}
+
+func validPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return errors.New("must have a port between 1 and 65535")
+	}
+	return nil
+}
+
+func validURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("must be a valid URL")
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("must use one of the schemes %s", strings.Join(schemes, ", "))
+}
+
+// Write prints the config as NAME=value lines, redacting secrets
+// and the passwords of URLs
+func Write(cfg any, w io.Writer) {
+	for _, f := range fieldsOf(cfg) {
+		value := fmt.Sprint(f.value.Interface())
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = u.Redacted()
+		}
+		if f.secret && value != "" {
+			value = "******"
+		}
+		fmt.Fprintf(w, "%s=%s\n", f.name, value)
+	}
+}
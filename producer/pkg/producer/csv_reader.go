@@ -0,0 +1,74 @@
+package producer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+)
+
+// csvReader is a wrapper for reading a CSV file in chunks,
+// tracking the file being read
+type csvReader struct {
+	in     *input
+	reader *csv.Reader
+}
+
+// NewCsvReader initializes a new csvReader for the given comma
+// separated file. Returns an error if the file does not exist or
+// cannot be opened. The file is hashed upfront, the hash identifies
+// its chunks
+func NewCsvReader(filename string) (*csvReader, error) {
+	in, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newCsvReader(in, ',', false), nil
+}
+
+func newCsvReader(in *input, delimiter rune, lazyQuotes bool) *csvReader {
+	reader := csv.NewReader(in)
+	reader.Comma = delimiter
+	reader.LazyQuotes = lazyQuotes
+
+	return &csvReader{
+		in:     in,
+		reader: reader,
+	}
+}
+
+// Read reads a chunk of records at time based on the give chunk
+// size, until the end of the file, returning io.EOF, or until ctx
+// is done. The header is skipped and so are the malformed lines,
+// which are sent along the records in Chunk.Malformed
+func (r *csvReader) Read(ctx context.Context, chunkSize int, chunkCh chan<- Chunk) error {
+	header := true
+	return readChunks(ctx, r.in, chunkSize, chunkCh, func() (Record, error) {
+		for {
+			record, err := r.reader.Read()
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, &MalformedLineError{Filename: r.in.name, Line: parseErr.StartLine, Err: parseErr.Err}
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if header {
+				header = false
+				continue
+			}
+			return record, nil
+		}
+	})
+}
+
+// FileHash returns the hex SHA-256 of the file, empty for a
+// stream not read to the end yet
+func (r *csvReader) FileHash() string {
+	return r.in.fileHash()
+}
+
+// Close closes the file
+func (r *csvReader) Close() error {
+	return r.in.Close()
+}
@@ -1,12 +1,17 @@
 package producer
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 )
 
 const (
 	IgnoredValue = "-1"
+
+	// id, first_name, last_name, email_address, created_at,
+	// deleted_at, merged_at, parent_user_id
+	userRecordFields = 8
 )
 
 type emailCryptor interface {
@@ -24,6 +29,10 @@ func NewCsvUserParser(cryptor emailCryptor) *csvUserParser {
 }
 
 func (p *csvUserParser) Parse(r Record) (*User, error) {
+	if len(r) < userRecordFields {
+		return nil, fmt.Errorf("expected %d fields, got %d", userRecordFields, len(r))
+	}
+
 	id, err := toInt64(r[0])
 	if err != nil {
 		return nil, err
@@ -0,0 +1,58 @@
+package producer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ListFiles expands path to the files to read, in lexical order. A
+// directory gives its files and a glob pattern the files matching
+// it, hidden files apart. Stdin and plain files are kept as they are
+func ListFiles(path string) ([]string, error) {
+	if path == Stdin {
+		return []string{path}, nil
+	}
+
+	var candidates []string
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && !info.IsDir():
+		return []string{path}, nil
+	case err == nil:
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			candidates = append(candidates, filepath.Join(path, e.Name()))
+		}
+	case errors.Is(err, fs.ErrNotExist) && strings.ContainsAny(path, `*?[`):
+		candidates, err = filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", path, err)
+		}
+	default:
+		return nil, err
+	}
+
+	var files []string
+	for _, c := range candidates {
+		if strings.HasPrefix(filepath.Base(c), ".") {
+			continue
+		}
+		if info, err := os.Stat(c); err == nil && info.Mode().IsRegular() {
+			files = append(files, c)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found in %q", path)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,128 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Ledger records the files already ingested
+type Ledger interface {
+	Ingested(hash string) (*IngestedFile, bool)
+	Record(f IngestedFile) error
+}
+
+type filesProducer struct {
+	files    []string
+	opts     ReaderOptions
+	parser   Parser
+	observer Observer
+	ledger   Ledger
+	workers  int
+	parallel int
+
+	amqpAdapter AmqpAdapter
+}
+
+// NewFilesProducer creates a producer for a list of files, each
+// one read with opts and published by its own userProducer
+func NewFilesProducer(files []string, opts ReaderOptions, p Parser, amqpAdapter AmqpAdapter) *filesProducer {
+	return &filesProducer{
+		files:       files,
+		opts:        opts,
+		parser:      p,
+		observer:    LogObserver{},
+		parallel:    1,
+		amqpAdapter: amqpAdapter,
+	}
+}
+
+// WithObserver replaces the default observer, which only
+// logs rejected records. It is shared by all the files
+func (f *filesProducer) WithObserver(o Observer) *filesProducer {
+	f.observer = o
+	return f
+}
+
+// WithWorkers sets how many chunks of every file are parsed
+// at once, see userProducer.WithWorkers
+func (f *filesProducer) WithWorkers(n int) *filesProducer {
+	f.workers = n
+	return f
+}
+
+// WithParallelism sets how many files are produced at once,
+// one by default
+func (f *filesProducer) WithParallelism(n int) *filesProducer {
+	if n > 0 {
+		f.parallel = n
+	}
+	return f
+}
+
+// WithLedger skips the files found in the ledger and records
+// the ones fully published
+func (f *filesProducer) WithLedger(l Ledger) *filesProducer {
+	f.ledger = l
+	return f
+}
+
+// Produce publishes the files in their order, or up to the
+// parallelism at once. A failed file does not stop the others,
+// the errors of all the failed files are returned
+func (f *filesProducer) Produce(ctx context.Context, chunkSize int) error {
+	errs := make([]error, len(f.files))
+
+	var g errgroup.Group
+	g.SetLimit(f.parallel)
+	for i, filename := range f.files {
+		i, filename := i, filename
+		g.Go(func() error {
+			if err := f.produceFile(ctx, filename, chunkSize); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", filename, err)
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (f *filesProducer) produceFile(ctx context.Context, filename string, chunkSize int) error {
+	reader, err := openReader(filename, f.opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// streams are only hashed once read, so they are always published
+	if hash := reader.FileHash(); f.ledger != nil && hash != "" {
+		if done, ok := f.ledger.Ingested(hash); ok {
+			log.Printf("skipping %s, already ingested from %s at %s", filename, done.Filename, done.CompletedAt.Format(time.RFC3339))
+			return nil
+		}
+	}
+
+	err = NewUserProducer(reader, f.parser, f.amqpAdapter).
+		WithObserver(f.observer).
+		WithWorkers(f.workers).
+		Produce(ctx, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	// every batch was confirmed by the queue, the file is ingested
+	if f.ledger == nil {
+		return nil
+	}
+	return f.ledger.Record(IngestedFile{
+		Filename:    filename,
+		Hash:        reader.FileHash(),
+		CompletedAt: time.Now().UTC(),
+	})
+}
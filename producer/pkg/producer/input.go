@@ -0,0 +1,175 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Stdin is the filename that reads the standard input
+const Stdin = "-"
+
+type compression int
+
+const (
+	uncompressed compression = iota
+	gzipCompressed
+	zstdCompressed
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// input is an opened file, decompressed on the fly when needed
+type input struct {
+	io.Reader
+	name string
+	// hash is the hex SHA-256 of the raw content of the file, taken
+	// upfront. Streams are hashed as they are read into sum instead
+	hash string
+	sum  hash.Hash
+	// eof is set once the stream is read to the end
+	eof     bool
+	closers []func() error
+}
+
+// openInput opens the file, or the standard input when filename is
+// Stdin, and hashes it upfront. The standard input is streamed, see
+// streamInput
+func openInput(filename string) (_ *input, err error) {
+	if filename == Stdin {
+		return streamInput(filename, os.Stdin)
+	}
+
+	in := &input{name: filename}
+	defer func() {
+		if err != nil {
+			in.Close()
+		}
+	}()
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	in.closers = append(in.closers, f.Close)
+
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	in.hash = hex.EncodeToString(sum.Sum(nil))
+
+	if err := in.decompress(bufio.NewReader(f)); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// streamInput reads r as it comes, without storing it. Its hash is
+// only known once it is read to the end, so its chunks are identified
+// by the hash of their records instead, see readChunks
+func streamInput(name string, r io.Reader) (_ *input, err error) {
+	in := &input{
+		name: name,
+		sum:  sha256.New(),
+	}
+	defer func() {
+		if err != nil {
+			in.Close()
+		}
+	}()
+
+	if err := in.decompress(bufio.NewReader(io.TeeReader(r, in.sum))); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// decompress sets the reader of in, decompressing buf when needed
+func (in *input) decompress(buf *bufio.Reader) error {
+	switch compressionOf(in.name, buf) {
+	case gzipCompressed:
+		zr, err := gzip.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		in.Reader = zr
+		in.closers = append(in.closers, zr.Close)
+	case zstdCompressed:
+		zr, err := zstd.NewReader(buf)
+		if err != nil {
+			return err
+		}
+		in.Reader = zr
+		in.closers = append(in.closers, func() error {
+			zr.Close()
+			return nil
+		})
+	default:
+		in.Reader = buf
+	}
+	return nil
+}
+
+// streamed reports whether the input is read as it comes
+func (in *input) streamed() bool {
+	return in.sum != nil
+}
+
+// fileHash returns the hash of the raw content, empty for a
+// stream not read to the end yet
+func (in *input) fileHash() string {
+	if !in.streamed() {
+		return in.hash
+	}
+	if !in.eof {
+		return ""
+	}
+	return hex.EncodeToString(in.sum.Sum(nil))
+}
+
+// compressionOf tells the compression of the file by its extension,
+// or else by its first bytes
+func compressionOf(filename string, r *bufio.Reader) compression {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz":
+		return gzipCompressed
+	case ".zst", ".zstd":
+		return zstdCompressed
+	}
+
+	head, _ := r.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzipCompressed
+	case bytes.HasPrefix(head, zstdMagic):
+		return zstdCompressed
+	}
+	return uncompressed
+}
+
+// Close releases the file, in the reverse order it was opened
+func (in *input) Close() error {
+	var errs []error
+	for i := len(in.closers) - 1; i >= 0; i-- {
+		errs = append(errs, in.closers[i]())
+	}
+	in.closers = nil
+	return errors.Join(errs...)
+}
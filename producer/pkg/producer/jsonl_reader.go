@@ -0,0 +1,125 @@
+package producer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// jsonlFields are the keys of a JSON Lines user, in the order
+// of the CSV columns
+var jsonlFields = []string{
+	"id", "first_name", "last_name", "email_address",
+	"created_at", "deleted_at", "merged_at", "parent_user_id",
+}
+
+// jsonlTimeFields may be RFC 3339 strings as well as milliseconds
+var jsonlTimeFields = map[string]bool{
+	"created_at": true,
+	"deleted_at": true,
+	"merged_at":  true,
+}
+
+// jsonlReader reads a file with a JSON object per line, as the
+// API exports them, turning every object into the record of the
+// equivalent CSV line
+type jsonlReader struct {
+	in     *input
+	reader *bufio.Reader
+}
+
+func newJSONLReader(in *input) *jsonlReader {
+	return &jsonlReader{
+		in:     in,
+		reader: bufio.NewReader(in),
+	}
+}
+
+// Read reads a chunk of records at time based on the give chunk
+// size, until the end of the file, returning io.EOF, or until ctx
+// is done. Blank lines are skipped and so are the malformed ones,
+// which are sent along the records in Chunk.Malformed
+func (r *jsonlReader) Read(ctx context.Context, chunkSize int, chunkCh chan<- Chunk) error {
+	line := 0
+	return readChunks(ctx, r.in, chunkSize, chunkCh, func() (Record, error) {
+		for {
+			data, err := r.reader.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(data) == 0) {
+				return nil, err
+			}
+			line++
+
+			data = bytes.TrimSpace(data)
+			if len(data) == 0 {
+				continue
+			}
+
+			record, err := jsonlRecord(data)
+			if err != nil {
+				return nil, &MalformedLineError{Filename: r.in.name, Line: line, Err: err}
+			}
+			return record, nil
+		}
+	})
+}
+
+// jsonlRecord converts a JSON object to a record, missing
+// and null values become IgnoredValue
+func jsonlRecord(data []byte) (Record, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+
+	record := make(Record, len(jsonlFields))
+	for i, key := range jsonlFields {
+		value, err := jsonlValue(obj[key], jsonlTimeFields[key])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		record[i] = value
+	}
+	return record, nil
+}
+
+func jsonlValue(raw json.RawMessage, isTime bool) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return IgnoredValue, nil
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		return v.String(), nil
+	case string:
+		if isTime {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return strconv.FormatInt(t.UnixMilli(), 10), nil
+			}
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("expected a string or a number, got %s", raw)
+}
+
+// FileHash returns the hex SHA-256 of the file, empty for a
+// stream not read to the end yet
+func (r *jsonlReader) FileHash() string {
+	return r.in.fileHash()
+}
+
+// Close closes the file
+func (r *jsonlReader) Close() error {
+	return r.in.Close()
+}
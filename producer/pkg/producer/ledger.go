@@ -0,0 +1,89 @@
+package producer
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// IngestedFile is a file whose batches were all published
+type IngestedFile struct {
+	Filename    string    `json:"filename"`
+	Hash        string    `json:"hash"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// fileLedger keeps the ingested files in a JSON file, keyed by
+// their hash so a renamed or copied file is still recognized
+type fileLedger struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]IngestedFile
+}
+
+// NewFileLedger loads the files recorded in the JSON file at path,
+// which is created by the first record
+func NewFileLedger(path string) (*fileLedger, error) {
+	l := &fileLedger{
+		path:  path,
+		files: map[string]IngestedFile{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.files); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Ingested returns the file recorded with the given hash
+func (l *fileLedger) Ingested(hash string) (*IngestedFile, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.files[hash]
+	if !ok {
+		return nil, false
+	}
+	return &f, true
+}
+
+// Record adds the file and saves the ledger. The ledger is written
+// to a temporary file renamed over the previous one, so a crash
+// leaves either the old or the new version
+func (l *fileLedger) Record(f IngestedFile) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.files[f.Hash] = f
+	data, err := json.MarshalIndent(l.files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
@@ -0,0 +1,150 @@
+package producer
+
+import (
+	"context"
+	"desafio/pkg/rabbitmq"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rabbitMQAdapter defines an adapter for RabbitMQ
+type rabbitmqAdapter struct {
+	queue string
+	conn  *rabbitmq.Connection
+}
+
+// NewRabbitMQAdapter creates a RabbitMQ adapter
+// returns an error if any issue connectin to the
+// server occours. Its channel is in confirm mode, so a
+// publish only succeeds once the broker took the message.
+// A lost connection is dialed again in background until Close
+func NewRabbitMQAdapter(url, queeu string) (*rabbitmqAdapter, error) {
+	conn, err := rabbitmq.NewConnection(url, func(channel *amqp.Channel) error {
+		if _, err := channel.QueueDeclare(queeu, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare RabbitMQ queue: %w", err)
+		}
+		if err := channel.Confirm(false); err != nil {
+			return fmt.Errorf("failed to enable RabbitMQ publisher confirms: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rabbitmqAdapter{
+		queue: queeu,
+		conn:  conn,
+	}, nil
+}
+
+// Publish sends a persistent message with the given id and headers
+// to the queue and waits for the broker to confirm it, propagating
+// the trace context of ctx through the headers as well
+func (r *rabbitmqAdapter) Publish(ctx context.Context, messageID, message, contentType string, headers map[string]string) error {
+	ctx, span := tracer.Start(ctx, "rabbitmq.Publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", r.queue),
+		))
+	defer span.End()
+
+	table := amqp.Table{}
+	for k, v := range headers {
+		table[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(table))
+
+	confirm, err := r.conn.Channel().PublishWithDeferredConfirmWithContext(
+		ctx,
+		"",      // Exchange
+		r.queue, // Routing key (queue name)
+		false,   // Mandatory
+		false,   // Immediate
+		amqp.Publishing{
+			Headers:      table,
+			ContentType:  contentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Body:         []byte(message),
+		})
+	if err == nil {
+		var acked bool
+		acked, err = confirm.WaitContext(ctx)
+		if err == nil && !acked {
+			err = fmt.Errorf("message %s was rejected by the broker", messageID)
+		}
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Consume starts consuming messages from queue
+func (r *rabbitmqAdapter) Consume(handler func(msg string) error) error {
+	msgs, err := r.conn.Channel().Consume(
+		r.queue, // Queue name
+		"",      // Consumer
+		true,    // Auto-ack
+		false,   // Exclusive
+		false,   // No-local
+		false,   // No-wait
+		nil,     // Arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming messages: %w", err)
+	}
+
+	// Start a goroutine to handle the consumed messages
+	go func() {
+		for d := range msgs {
+			err := handler(string(d.Body))
+			if err != nil {
+				log.Printf("error handling message: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// HeaderCarrier adapts AMQP headers to carry the trace context
+type HeaderCarrier amqp.Table
+
+func (c HeaderCarrier) Get(key string) string {
+	v, _ := c[key].(string)
+	return v
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Status returns an error if the connection or the channel is
+// closed, which lasts until it is dialed again
+func (r *rabbitmqAdapter) Status() error {
+	return r.conn.Status()
+}
+
+// Close closes the connection and channel to RabbitMQ, and stops
+// dialing again
+func (r *rabbitmqAdapter) Close() {
+	r.conn.Close()
+}
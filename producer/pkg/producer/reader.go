@@ -0,0 +1,256 @@
+package producer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format is the layout of the records of a file
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatTSV   Format = "tsv"
+	FormatJSONL Format = "jsonl"
+)
+
+// ReaderOptions describes the file to read, the zero value
+// guesses the format from the file extension
+type ReaderOptions struct {
+	Format Format
+	// Delimiter separates the fields of CSV files, a comma by
+	// default or a tab for TSV
+	Delimiter rune
+	// LazyQuotes accepts quotes in unquoted fields and
+	// unescaped quotes in quoted fields
+	LazyQuotes bool
+}
+
+// NewReader opens the file, or the standard input when filename is
+// Stdin, to read it in the format of opts. Gzip and zstd files are
+// decompressed, found by their extension or their first bytes
+func NewReader(filename string, opts ReaderOptions) (FileReader, error) {
+	return openReader(filename, opts)
+}
+
+// NewStreamReader reads r in the format of opts as it comes, name
+// standing for the file in the chunks and guessing the format. The
+// caller closes r
+func NewStreamReader(name string, r io.Reader, opts ReaderOptions) (FileReader, error) {
+	return newReader(name, opts, func() (*input, error) {
+		return streamInput(name, r)
+	})
+}
+
+// hashedReader is a FileReader that knows the hash of its file,
+// streams only once they are read to the end
+type hashedReader interface {
+	FileReader
+	FileHash() string
+}
+
+func openReader(filename string, opts ReaderOptions) (hashedReader, error) {
+	return newReader(filename, opts, func() (*input, error) {
+		return openInput(filename)
+	})
+}
+
+func newReader(filename string, opts ReaderOptions, open func() (*input, error)) (hashedReader, error) {
+	format := opts.Format
+	if format == "" {
+		format = formatOf(filename)
+	}
+
+	delimiter := opts.Delimiter
+	switch format {
+	case FormatCSV:
+		if delimiter == 0 {
+			delimiter = ','
+		}
+	case FormatTSV:
+		if delimiter == 0 {
+			delimiter = '\t'
+		}
+	case FormatJSONL:
+	default:
+		return nil, fmt.Errorf("unknown format %q, expected csv, tsv or jsonl", format)
+	}
+	if !validDelimiter(delimiter) {
+		return nil, fmt.Errorf("invalid delimiter %q", delimiter)
+	}
+
+	in, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatJSONL {
+		return newJSONLReader(in), nil
+	}
+	return newCsvReader(in, delimiter, opts.LazyQuotes), nil
+}
+
+// ParseDelimiter reads a delimiter given as a single character,
+// or as \t or tab
+func ParseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || !validDelimiter(r) {
+		return 0, fmt.Errorf("invalid delimiter %q, expected a single character", s)
+	}
+	return r, nil
+}
+
+func validDelimiter(r rune) bool {
+	return r != '"' && r != '\r' && r != '\n' && r != utf8.RuneError
+}
+
+// formatOf guesses the format from the extension, under the
+// compression one
+func formatOf(filename string) Format {
+	name := strings.ToLower(filename)
+	switch filepath.Ext(name) {
+	case ".gz", ".zst", ".zstd":
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	switch filepath.Ext(name) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".tsv":
+		return FormatTSV
+	}
+	return FormatCSV
+}
+
+// readChunks sends the records returned by next in chunks of
+// chunkSize, numbered from 0, until next returns io.EOF. The
+// malformed lines reported by next are kept in the chunk being
+// filled, so they are counted along its records. The chunks
+// of streams are identified by the hash of the records read up to
+// their end, which is the same for the same content
+func readChunks(ctx context.Context, in *input, chunkSize int, chunkCh chan<- Chunk, next func() (Record, error)) error {
+	var records hash.Hash
+	if in.streamed() {
+		records = sha256.New()
+	}
+	newChunk := func(index int) Chunk {
+		return Chunk{
+			Filename: in.name,
+			FileHash: in.hash,
+			Index:    index,
+			Records:  []Record{},
+		}
+	}
+	sendChunk := func(chunk Chunk) error {
+		if records != nil {
+			chunk.FileHash = hex.EncodeToString(records.Sum(nil))
+		}
+		return send(ctx, chunkCh, chunk)
+	}
+
+	chunk := newChunk(0)
+	for {
+		record, err := next()
+		if err == io.EOF {
+			in.eof = true
+			if len(chunk.Records) > 0 || len(chunk.Malformed) > 0 {
+				if err := sendChunk(chunk); err != nil {
+					return err
+				}
+			}
+			return io.EOF
+		}
+		var malformed *MalformedLineError
+		if errors.As(err, &malformed) {
+			chunk.Malformed = append(chunk.Malformed, malformed)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk.Records = append(chunk.Records, record)
+		if records != nil {
+			for _, field := range record {
+				records.Write([]byte(field))
+				records.Write([]byte{0x1f})
+			}
+			records.Write([]byte{0x1e})
+		}
+
+		if len(chunk.Records) == chunkSize {
+			if err := sendChunk(chunk); err != nil {
+				return err
+			}
+			chunk = newChunk(chunk.Index + 1)
+		}
+	}
+}
+
+// send sends v to ch unless ctx is done first
+func send[T any](ctx context.Context, ch chan<- T, v T) error {
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Chunk represents a portion of the file, containing
+// successfully processed records and any failures
+// encountered during processing
+type Chunk struct {
+	Filename string
+	// FileHash is the hex SHA-256 of the file content or, for the
+	// streams that cannot be hashed upfront, of the records read
+	// up to the end of the chunk
+	FileHash string
+	// Index is the position of the chunk in the file, from 0, the
+	// chunks are published in this order
+	Index   int
+	Records []Record
+	// Malformed are the lines skipped while reading the records
+	Malformed []*MalformedLineError
+}
+
+// MalformedLineError is a line of the file that could not be
+// read as a record, it is skipped
+type MalformedLineError struct {
+	Filename string
+	Line     int
+	Err      error
+}
+
+func (e *MalformedLineError) Error() string {
+	return fmt.Sprintf("malformed line %d of %s: %v", e.Line, e.Filename, e.Err)
+}
+
+func (e *MalformedLineError) Unwrap() error {
+	return e.Err
+}
+
+// MessageID identifies the chunk, it is the same every time the
+// file is read with the same chunk size, so the consumers can drop
+// the chunks they already processed
+func (c Chunk) MessageID() string {
+	return fmt.Sprintf("%s-%d", c.FileHash, c.Index)
+}
+
+// Record represents a single record as a slice of string values,
+// in the order of the CSV columns
+type Record []string
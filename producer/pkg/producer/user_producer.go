@@ -0,0 +1,306 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"runtime"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+var tracer = otel.Tracer("desafio/pkg/producer")
+
+type FileReader interface {
+	// Read sends the chunks of the file to chunkCh, numbered from 0,
+	// returning io.EOF at the end of the file. It must return when
+	// ctx is done. The caller closes chunkCh
+	Read(ctx context.Context, chunkSize int, chunkCh chan<- Chunk) error
+	Close() error
+}
+
+type Parser interface {
+	Parse(r Record) (*User, error)
+}
+
+// Observer is notified about the outcome of the records
+// handled by the producer
+type Observer interface {
+	// Rejected is called for every record that could not be parsed,
+	// and for every malformed line with a nil record and a
+	// *MalformedLineError
+	Rejected(r Record, err error)
+	// Published is called after every batch sent to the queue
+	// with the number of users in the batch
+	Published(users int)
+}
+
+type userProducer struct {
+	reader   FileReader
+	parser   Parser
+	observer Observer
+	workers  int
+
+	amqpAdapter AmqpAdapter
+}
+
+// FilenameHeader is the message header holding the name of the
+// file the users were read from
+const FilenameHeader = "x-filename"
+
+type AmqpAdapter interface {
+	Publish(ctx context.Context, messageID, message, contentType string, headers map[string]string) error
+}
+
+// Discard is an AmqpAdapter dropping every message, for dry runs
+var Discard AmqpAdapter = discard{}
+
+type discard struct{}
+
+func (discard) Publish(ctx context.Context, messageID, message, contentType string, headers map[string]string) error {
+	return nil
+}
+
+func NewUserProducer(r FileReader, p Parser, amqpAdapter AmqpAdapter) *userProducer {
+	return &userProducer{
+		reader:      r,
+		parser:      p,
+		observer:    LogObserver{},
+		workers:     runtime.NumCPU(),
+		amqpAdapter: amqpAdapter,
+	}
+}
+
+// WithObserver replaces the default observer, which only
+// logs rejected records
+func (u *userProducer) WithObserver(o Observer) *userProducer {
+	u.observer = o
+	return u
+}
+
+// WithWorkers sets how many chunks are parsed at once, one per
+// CPU by default
+func (u *userProducer) WithWorkers(n int) *userProducer {
+	if n > 0 {
+		u.workers = n
+	}
+	return u
+}
+
+// Produce reads the file in chunks of chunkSize records, parses them
+// and publishes every chunk as a batch of users, in the order of the
+// file. The stages run concurrently:
+//
+//	read → parse (workers) → reorder → publish
+//
+// linked by channels holding up to one chunk per worker, so a slow
+// queue holds back the reading. At most one chunk per worker is
+// between parsing and publishing, so a slow chunk holds back the
+// parsing of the next ones instead of piling them up in reorder.
+// Records that cannot be parsed are reported to the observer and
+// skipped. The first error of any stage stops them all and is
+// returned
+func (u *userProducer) Produce(ctx context.Context, chunkSize int) (err error) {
+	ctx, span := tracer.Start(ctx, "producer.Produce",
+		trace.WithAttributes(attribute.Int("workers", u.workers)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	chunks := make(chan Chunk, u.workers)
+	g.Go(func() error {
+		defer close(chunks)
+		if err := u.reader.Read(ctx, chunkSize, chunks); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read chunk: %w", err)
+		}
+		return nil
+	})
+
+	// a parser takes a token before taking a chunk, reorder gives
+	// it back once the chunk is sent on
+	window := make(chan struct{}, u.workers)
+	parsed := make(chan parsedChunk, u.workers)
+	var parsers sync.WaitGroup
+	for i := 0; i < u.workers; i++ {
+		parsers.Add(1)
+		g.Go(func() error {
+			defer parsers.Done()
+			for {
+				if err := send(ctx, window, struct{}{}); err != nil {
+					return err
+				}
+				c, ok := <-chunks
+				if !ok {
+					return nil
+				}
+				if err := send(ctx, parsed, u.parse(ctx, c)); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	g.Go(func() error {
+		parsers.Wait()
+		close(parsed)
+		return nil
+	})
+
+	ordered := make(chan parsedChunk, u.workers)
+	g.Go(func() error {
+		defer close(ordered)
+		return reorder(ctx, parsed, ordered, func() { <-window })
+	})
+
+	g.Go(func() error {
+		for c := range ordered {
+			if err := u.publish(ctx, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
+
+// parsedChunk holds the users of a chunk, ready to be published
+type parsedChunk struct {
+	chunk    Chunk
+	users    []User
+	rejected []rejectedRecord
+}
+
+type rejectedRecord struct {
+	record Record
+	err    error
+}
+
+// parse parses and encrypts the records of the chunk within its
+// own span
+func (u *userProducer) parse(ctx context.Context, c Chunk) parsedChunk {
+	_, span := tracer.Start(ctx, "producer.parseChunk",
+		trace.WithAttributes(
+			attribute.String("file.name", c.Filename),
+			attribute.Int("chunk", c.Index),
+			attribute.Int("records", len(c.Records)),
+		))
+	defer span.End()
+
+	p := parsedChunk{
+		chunk: c,
+		users: make([]User, 0, len(c.Records)),
+	}
+	for _, m := range c.Malformed {
+		p.rejected = append(p.rejected, rejectedRecord{err: m})
+	}
+	for _, r := range c.Records {
+		user, err := u.parser.Parse(r)
+		if err != nil {
+			p.rejected = append(p.rejected, rejectedRecord{record: r, err: err})
+			continue
+		}
+		p.users = append(p.users, *user)
+	}
+	// the records are not needed anymore
+	p.chunk.Records = nil
+	p.chunk.Malformed = nil
+	return p
+}
+
+// reorder forwards the parsed chunks in the order of their index,
+// holding the ones parsed ahead of their turn, and calls release
+// after forwarding each of them
+func reorder(ctx context.Context, in <-chan parsedChunk, out chan<- parsedChunk, release func()) error {
+	ahead := map[int]parsedChunk{}
+	next := 0
+	for c := range in {
+		ahead[c.chunk.Index] = c
+		for {
+			c, ok := ahead[next]
+			if !ok {
+				break
+			}
+			delete(ahead, next)
+			if err := send(ctx, out, c); err != nil {
+				return err
+			}
+			release()
+			next++
+		}
+	}
+
+	// chunks are only missing when an earlier stage failed,
+	// which reports the error
+	return nil
+}
+
+// publish sends the users of the chunk as a single batch, within
+// its own span. The rejected records are reported first
+func (u *userProducer) publish(ctx context.Context, c parsedChunk) error {
+	ctx, span := tracer.Start(ctx, "producer.publishChunk",
+		trace.WithAttributes(
+			attribute.String("file.name", c.chunk.Filename),
+			attribute.String("messaging.message.id", c.chunk.MessageID()),
+			attribute.Int("users", len(c.users)),
+		))
+	defer span.End()
+
+	for _, r := range c.rejected {
+		u.observer.Rejected(r.record, r.err)
+	}
+
+	msg, err := json.Marshal(c.users)
+	if err != nil {
+		return fmt.Errorf("failed to marshal users to JSON: %w", err)
+	}
+
+	if err := u.amqpAdapter.Publish(ctx, c.chunk.MessageID(), string(msg), "application/json", map[string]string{
+		FilenameHeader: c.chunk.Filename,
+	}); err != nil {
+		return &PublishError{MessageID: c.chunk.MessageID(), Err: err}
+	}
+	u.observer.Published(len(c.users))
+
+	return nil
+}
+
+// PublishError is returned by Produce when the queue did not take
+// a batch, which is not the fault of the file
+type PublishError struct {
+	MessageID string
+	Err       error
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("failed to publish batch %s: %v", e.MessageID, e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// LogObserver is the default observer, it logs rejected records
+type LogObserver struct{}
+
+func (LogObserver) Rejected(r Record, err error) {
+	if r == nil {
+		log.Printf("skipping %v", err)
+		return
+	}
+	log.Printf("error parsing record on line %v", err)
+}
+
+func (LogObserver) Published(users int) {}
@@ -0,0 +1,154 @@
+package producer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chunksReader sends n chunks of a single record holding the
+// index of the chunk
+type chunksReader struct {
+	n int
+}
+
+func (r chunksReader) Read(ctx context.Context, chunkSize int, chunkCh chan<- Chunk) error {
+	for i := 0; i < r.n; i++ {
+		chunk := Chunk{
+			Filename: "users.csv",
+			FileHash: "hash",
+			Index:    i,
+			Records:  []Record{{strconv.Itoa(i)}},
+		}
+		if err := send(ctx, chunkCh, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (chunksReader) Close() error {
+	return nil
+}
+
+// delayParser parses the id of the record, sleeping longer for
+// the first records so the chunks are parsed out of order
+type delayParser struct {
+	n int
+}
+
+func (p *delayParser) Parse(r Record) (*User, error) {
+	id, err := strconv.ParseInt(r[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(time.Duration(p.n-int(id)) * time.Millisecond)
+	return &User{ID: id}, nil
+}
+
+// recordingQueue keeps the ids of the published messages
+type recordingQueue struct {
+	mu    sync.Mutex
+	ids   []string
+	users []int64
+}
+
+func (q *recordingQueue) Publish(ctx context.Context, messageID, message, contentType string, headers map[string]string) error {
+	var users []User
+	if err := json.Unmarshal([]byte(message), &users); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ids = append(q.ids, messageID)
+	for _, u := range users {
+		q.users = append(q.users, u.ID)
+	}
+	return nil
+}
+
+func TestProducePublishesInOrder(t *testing.T) {
+	const chunks = 50
+	queue := &recordingQueue{}
+
+	err := NewUserProducer(chunksReader{n: chunks}, &delayParser{n: chunks}, queue).
+		WithWorkers(8).
+		Produce(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+
+	if len(queue.ids) != chunks {
+		t.Fatalf("published %d messages, want %d", len(queue.ids), chunks)
+	}
+	for i, id := range queue.ids {
+		if want := fmt.Sprintf("hash-%d", i); id != want {
+			t.Errorf("message %d has id %s, want %s", i, id, want)
+		}
+		if queue.users[i] != int64(i) {
+			t.Errorf("message %d has user %d, want %d", i, queue.users[i], i)
+		}
+	}
+}
+
+// blockingParser holds the first record until released, signaling
+// every record parsed
+type blockingParser struct {
+	release chan struct{}
+	parsed  chan struct{}
+}
+
+func (p *blockingParser) Parse(r Record) (*User, error) {
+	if r[0] == "0" {
+		<-p.release
+	}
+	p.parsed <- struct{}{}
+	return &User{}, nil
+}
+
+func TestProduceBoundsTheChunksParsedAhead(t *testing.T) {
+	const (
+		chunks  = 100
+		workers = 4
+	)
+	parser := &blockingParser{
+		release: make(chan struct{}),
+		parsed:  make(chan struct{}, chunks),
+	}
+	queue := &recordingQueue{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewUserProducer(chunksReader{n: chunks}, parser, queue).
+			WithWorkers(workers).
+			Produce(context.Background(), 1)
+	}()
+
+	// the other workers parse what the window allows, then wait
+	// for the first chunk to be published
+	for i := 0; i < workers-1; i++ {
+		select {
+		case <-parser.parsed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("parsed %d chunks ahead of the first one, want %d", i, workers-1)
+		}
+	}
+	select {
+	case <-parser.parsed:
+		t.Errorf("parsed more than %d chunks ahead of the first one", workers-1)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(parser.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Produce() error = %v", err)
+	}
+	if len(queue.ids) != chunks {
+		t.Errorf("published %d messages, want %d", len(queue.ids), chunks)
+	}
+}
@@ -0,0 +1,246 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// examples listed for every kind of anomaly, the
+	// count covers them all
+	maxReportExamples = 20
+
+	validateChunkSize = 1000
+)
+
+// ValidationReport describes the users of the validated files
+// and their anomalies
+type ValidationReport struct {
+	Files int `json:"files"`
+	Rows  int `json:"rows"`
+	Valid int `json:"valid"`
+
+	Rejected        Anomaly `json:"rejected"`
+	DuplicateIDs    Anomaly `json:"duplicate_ids"`
+	DuplicateEmails Anomaly `json:"duplicate_emails"`
+	MissingParents  Anomaly `json:"missing_parents"`
+	// DeletedAndMerged are the users both deleted and merged, or
+	// merged without the parent they were merged into
+	DeletedAndMerged Anomaly `json:"deleted_and_merged"`
+	// Timestamps are the users created in the future or at the
+	// epoch, or deleted or merged before being created
+	Timestamps Anomaly `json:"timestamps"`
+}
+
+// Anomaly counts the occurrences of an issue, listing the first ones
+type Anomaly struct {
+	Count    int      `json:"count"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+func (a *Anomaly) add(format string, args ...any) {
+	a.Count++
+	if len(a.Examples) < maxReportExamples {
+		a.Examples = append(a.Examples, fmt.Sprintf(format, args...))
+	}
+}
+
+// OK reports whether no anomaly was found
+func (r *ValidationReport) OK() bool {
+	for _, a := range r.anomalies() {
+		if a.anomaly.Count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Write prints the report in a human readable form
+func (r *ValidationReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "files: %d\n", r.Files)
+	fmt.Fprintf(w, "rows: %d (%d valid)\n", r.Rows, r.Valid)
+	for _, a := range r.anomalies() {
+		fmt.Fprintf(w, "%s: %d\n", a.name, a.anomaly.Count)
+		for _, e := range a.anomaly.Examples {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+		if more := a.anomaly.Count - len(a.anomaly.Examples); more > 0 {
+			fmt.Fprintf(w, "  ... %d more\n", more)
+		}
+	}
+}
+
+type namedAnomaly struct {
+	name    string
+	anomaly Anomaly
+}
+
+func (r *ValidationReport) anomalies() []namedAnomaly {
+	return []namedAnomaly{
+		{"rejected rows", r.Rejected},
+		{"duplicate ids", r.DuplicateIDs},
+		{"duplicate emails", r.DuplicateEmails},
+		{"missing parents", r.MissingParents},
+		{"deleted and merged", r.DeletedAndMerged},
+		{"timestamp anomalies", r.Timestamps},
+	}
+}
+
+// Validate reads the files, without publishing them, and reports
+// the anomalies of their users. The emails are left in clear to be
+// compared, ignoring their case
+func Validate(ctx context.Context, files []string, opts ReaderOptions) (*ValidationReport, error) {
+	v := &validator{
+		parser: NewCsvUserParser(plainText{}),
+		now:    time.Now(),
+		ids:    map[int64]int{},
+		emails: map[string][]int64{},
+	}
+	for _, f := range files {
+		if err := v.addFile(ctx, f, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+	}
+	return v.finish(), nil
+}
+
+// plainText leaves the emails unchanged
+type plainText struct{}
+
+func (plainText) Encrypt(val string) (string, error) {
+	return val, nil
+}
+
+// validator collects the anomalies found row by row, and the ids
+// and emails to look for the ones spanning several rows
+type validator struct {
+	parser Parser
+	now    time.Time
+	report ValidationReport
+
+	ids     map[int64]int
+	emails  map[string][]int64
+	parents [][2]int64
+}
+
+func (v *validator) addFile(ctx context.Context, filename string, opts ReaderOptions) error {
+	reader, err := openReader(filename, opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan Chunk, 1)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		readErr <- reader.Read(ctx, validateChunkSize, chunks)
+	}()
+
+	for c := range chunks {
+		for _, m := range c.Malformed {
+			v.report.Rows++
+			v.report.Rejected.add("%v", m)
+		}
+		for _, r := range c.Records {
+			v.add(r)
+		}
+	}
+	if err := <-readErr; err != io.EOF {
+		return err
+	}
+
+	v.report.Files++
+	return nil
+}
+
+func (v *validator) add(r Record) {
+	v.report.Rows++
+
+	user, err := v.parser.Parse(r)
+	if err != nil {
+		id := "?"
+		if len(r) > 0 {
+			id = r[0]
+		}
+		v.report.Rejected.add("id %s: %v", id, err)
+		return
+	}
+	v.report.Valid++
+
+	v.ids[user.ID]++
+	if email := strings.ToLower(strings.TrimSpace(user.Email)); email != "" && email != IgnoredValue {
+		v.emails[email] = append(v.emails[email], user.ID)
+	}
+	if user.ParentUserID != nil {
+		v.parents = append(v.parents, [2]int64{user.ID, *user.ParentUserID})
+	}
+
+	switch {
+	case user.DeletedAt != nil && user.MergedAt != nil:
+		v.report.DeletedAndMerged.add("id %d is deleted and merged", user.ID)
+	case user.MergedAt != nil && user.ParentUserID == nil:
+		v.report.DeletedAndMerged.add("id %d is merged without a parent", user.ID)
+	}
+
+	switch {
+	case user.CreatedAt.After(v.now):
+		v.report.Timestamps.add("id %d is created in the future, at %s", user.ID, user.CreatedAt.Format(time.RFC3339))
+	case user.CreatedAt.Unix() <= 0:
+		v.report.Timestamps.add("id %d is created at %s", user.ID, user.CreatedAt.Format(time.RFC3339))
+	case user.DeletedAt != nil && user.DeletedAt.Before(user.CreatedAt):
+		v.report.Timestamps.add("id %d is deleted before being created", user.ID)
+	case user.MergedAt != nil && user.MergedAt.Before(user.CreatedAt):
+		v.report.Timestamps.add("id %d is merged before being created", user.ID)
+	}
+}
+
+// finish looks for the anomalies spanning several rows, in the
+// order of the ids
+func (v *validator) finish() *ValidationReport {
+	ids := make([]int64, 0, len(v.ids))
+	for id, n := range v.ids {
+		if n > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		v.report.DuplicateIDs.add("id %d appears %d times", id, v.ids[id])
+	}
+
+	emails := make([]string, 0)
+	for email, users := range v.emails {
+		if len(users) > 1 {
+			emails = append(emails, email)
+		}
+	}
+	sort.Strings(emails)
+	for _, email := range emails {
+		v.report.DuplicateEmails.add("%s is used by ids %s", email, joinIDs(v.emails[email]))
+	}
+
+	sort.Slice(v.parents, func(i, j int) bool { return v.parents[i][0] < v.parents[j][0] })
+	for _, p := range v.parents {
+		if v.ids[p[1]] == 0 {
+			v.report.MissingParents.add("id %d has parent %d, which is not in the files", p[0], p[1])
+		}
+	}
+
+	return &v.report
+}
+
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,250 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// ProcessedDir and FailedDir are the subdirectories of the
+	// watched directory the files are moved to once published
+	ProcessedDir = "processed"
+	FailedDir    = "failed"
+
+	// files modified more recently are assumed to be still
+	// being written
+	watchSettle = 2 * time.Second
+)
+
+type dirWatcher struct {
+	dir     string
+	poll    time.Duration
+	produce func(ctx context.Context, filename string) error
+
+	mu      sync.Mutex
+	running bool
+	err     error
+	// stuck holds the files that could not be moved away,
+	// so they are not published again
+	stuck map[string]bool
+}
+
+// NewDirWatcher creates a watcher publishing with produce every file
+// landing in dir. The directory is polled every poll when it cannot
+// be watched for changes
+func NewDirWatcher(dir string, poll time.Duration, produce func(ctx context.Context, filename string) error) *dirWatcher {
+	return &dirWatcher{
+		dir:     dir,
+		poll:    poll,
+		produce: produce,
+		stuck:   map[string]bool{},
+	}
+}
+
+// Run publishes the files found in the directory, then every file
+// landing there until ctx is done. Every file is moved to the
+// processed or failed subdirectory once published, so it is only
+// published once. A file the queue did not take stays in place, and
+// the scan is tried again after the poll interval. Hidden files are
+// ignored
+func (w *dirWatcher) Run(ctx context.Context) error {
+	for _, sub := range []string{ProcessedDir, FailedDir} {
+		if err := os.MkdirAll(filepath.Join(w.dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	w.setRunning(true)
+	defer w.setRunning(false)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+		poll   <-chan time.Time
+	)
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		defer watcher.Close()
+		err = watcher.Add(w.dir)
+	}
+	if err != nil {
+		log.Printf("polling %s every %s, it cannot be watched: %v", w.dir, w.poll, err)
+		ticker := time.NewTicker(w.poll)
+		defer ticker.Stop()
+		poll = ticker.C
+	} else {
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	// the first scan picks the files that landed while stopped. The
+	// events schedule a scan once the files settle, at most one at a
+	// time so a steady flow of files does not keep delaying it
+	settle := time.NewTimer(0)
+	defer settle.Stop()
+	scheduled := true
+	schedule := func(d time.Duration) {
+		if !scheduled {
+			resetTimer(settle, d)
+			scheduled = true
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-events:
+			schedule(watchSettle)
+			continue
+		case err := <-errs:
+			// events may have been lost, scanning finds their files
+			log.Printf("error watching %s: %v", w.dir, err)
+		case <-poll:
+		case <-settle.C:
+			scheduled = false
+		}
+
+		pending, retry, err := w.scan(ctx)
+		w.setErr(err)
+		if err != nil {
+			log.Printf("failed to scan %s: %v", w.dir, err)
+		}
+		switch {
+		case retry:
+			schedule(w.poll)
+		case pending:
+			schedule(watchSettle)
+		}
+	}
+}
+
+// scan publishes the files of the directory in lexical order,
+// reporting whether some are still being written, and whether it
+// stopped at a file the queue did not take
+func (w *dirWatcher) scan(ctx context.Context) (pending, retry bool, err error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return false, false, err
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return false, false, nil
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || w.isStuck(e.Name()) {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(w.dir, e.Name()))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if time.Since(info.ModTime()) < watchSettle {
+			pending = true
+			continue
+		}
+
+		// the next files would not be taken either
+		if !w.process(ctx, e.Name()) {
+			return pending, true, nil
+		}
+	}
+	return pending, false, nil
+}
+
+// process publishes the file and moves it away, reporting false
+// when the queue did not take it. It is then left in place, the
+// batches already sent are dropped by the consumers when sent again.
+// Every batch waits for the broker to confirm it, so one lost by the
+// broker fails the publish too instead of reaching processed/
+func (w *dirWatcher) process(ctx context.Context, name string) bool {
+	path := filepath.Join(w.dir, name)
+
+	sub := ProcessedDir
+	if err := w.produce(ctx, path); err != nil {
+		// stopped halfway, the file is published again on restart
+		if ctx.Err() != nil {
+			return true
+		}
+		var pubErr *PublishError
+		if errors.As(err, &pubErr) {
+			log.Printf("failed to publish %s, retrying in %s: %v", path, w.poll, err)
+			return false
+		}
+		log.Printf("failed to publish %s: %v", path, err)
+		sub = FailedDir
+	}
+
+	target, err := moveFile(path, filepath.Join(w.dir, sub))
+	if err != nil {
+		log.Printf("failed to move %s to %s, it is ignored until restart: %v", path, sub, err)
+		w.mu.Lock()
+		w.stuck[name] = true
+		w.mu.Unlock()
+		return true
+	}
+	log.Printf("moved %s to %s", path, target)
+	return true
+}
+
+// moveFile moves the file to dir, prefixing its name with the
+// current time when the name is taken
+func moveFile(path, dir string) (string, error) {
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Lstat(target); err == nil {
+		target = filepath.Join(dir, fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405.000000000"), filepath.Base(path)))
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return target, os.Rename(path, target)
+}
+
+// resetTimer resets t, draining its channel if it already fired
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+// Status returns an error if the watcher is not running or its
+// last scan of the directory failed
+func (w *dirWatcher) Status() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.running {
+		return errors.New("watcher is not running")
+	}
+	return w.err
+}
+
+func (w *dirWatcher) isStuck(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stuck[name]
+}
+
+func (w *dirWatcher) setRunning(running bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running = running
+}
+
+func (w *dirWatcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
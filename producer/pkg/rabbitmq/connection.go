@@ -0,0 +1,167 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RedialInterval is the time between the attempts to reconnect
+// once the connection to RabbitMQ is lost
+const RedialInterval = 5 * time.Second
+
+// Setup prepares every channel opened, declaring what it uses
+// and setting its modes. It runs again after each reconnection
+type Setup func(channel *amqp.Channel) error
+
+// Connection is a connection to RabbitMQ and a channel on it,
+// both dialed again in background when either is closed
+type Connection struct {
+	url   string
+	setup Setup
+
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	// done is closed by Close, stopping the reconnections
+	done chan struct{}
+}
+
+// NewConnection connects to RabbitMQ and opens a channel prepared
+// by setup, returns an error if any of it fails. A lost connection
+// is dialed again every RedialInterval until Close
+func NewConnection(url string, setup Setup) (*Connection, error) {
+	c := &Connection{
+		url:   url,
+		setup: setup,
+		done:  make(chan struct{}),
+	}
+
+	conn, channel, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.conn, c.channel = conn, channel
+
+	go c.redial(conn, channel)
+
+	return c, nil
+}
+
+// dial connects to RabbitMQ and opens a channel, running setup on it
+func (c *Connection) dial() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to create RabbitMQ channel: %w", err)
+	}
+
+	if c.setup != nil {
+		if err := c.setup(channel); err != nil {
+			channel.Close()
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+
+	return conn, channel, nil
+}
+
+// redial waits for the connection or its channel to close, then
+// dials again until it succeeds. The channel may be closed alone,
+// by a failed operation, the connection is replaced all the same
+func (c *Connection) redial(conn *amqp.Connection, channel *amqp.Channel) {
+	for {
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+
+		var reason *amqp.Error
+		select {
+		case <-c.done:
+			return
+		case reason = <-connClosed:
+		case reason = <-channelClosed:
+		}
+		slog.Error("rabbitmq", slog.Group("redial", "closed", reason))
+
+		channel.Close()
+		conn.Close()
+
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(RedialInterval):
+			}
+
+			var err error
+			conn, channel, err = c.dial()
+			if err == nil {
+				break
+			}
+			slog.Error("rabbitmq", slog.Group("redial", "dial", err))
+		}
+
+		c.mu.Lock()
+		select {
+		case <-c.done:
+			c.mu.Unlock()
+			channel.Close()
+			conn.Close()
+			return
+		default:
+		}
+		c.conn, c.channel = conn, channel
+		c.mu.Unlock()
+		slog.Info("rabbitmq", slog.Group("redial", "reconnected", true))
+	}
+}
+
+// Channel returns the channel in use. Its operations fail while
+// the connection is lost, until it is dialed again
+func (c *Connection) Channel() *amqp.Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.channel
+}
+
+// Conn returns the connection in use, to open short lived channels
+func (c *Connection) Conn() *amqp.Connection {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn
+}
+
+// Status returns an error if the connection or the channel is
+// closed, which lasts until it is dialed again
+func (c *Connection) Status() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn.IsClosed() {
+		return errors.New("connection is closed")
+	}
+	if c.channel.IsClosed() {
+		return errors.New("channel is closed")
+	}
+	return nil
+}
+
+// Close closes the connection and channel, and stops dialing again
+func (c *Connection) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	close(c.done)
+	c.channel.Close()
+	c.conn.Close()
+}
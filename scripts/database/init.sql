@@ -9,8 +9,20 @@ CREATE TABLE IF NOT EXISTS challenge.users (
     deleted_at TIMESTAMPTZ,          
     merged_at TIMESTAMPTZ,          
     parent_user_id BIGINT,         
+    version BIGINT NOT NULL DEFAULT 1,
     CONSTRAINT fk_parent_user
         FOREIGN KEY (parent_user_id)
         REFERENCES challenge.users(id)
         ON DELETE SET NULL
 );
+
+-- events of the user writes, inserted in the same transaction as the
+-- write and removed once the broker confirmed them
+CREATE TABLE IF NOT EXISTS challenge.user_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    user_id BIGINT NOT NULL,
+    payload JSONB NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);